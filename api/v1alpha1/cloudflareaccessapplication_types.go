@@ -17,9 +17,13 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"slices"
+
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfcollections"
 	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -79,6 +83,27 @@ type CloudflareAccessApplicationSpec struct {
 	// The image URL for the logo shown in the App Launcher dashboard
 	// +optional
 	LogoURL string `json:"logoUrl,omitempty"`
+
+	// ServiceTokenBindings permits CloudflareServiceTokens listing the application in spec.applications to be
+	// granted access through a managed non_identity policy, evaluated after the policies above.
+	// Bindings are ignored unless permitted
+	// +optional
+	ServiceTokenBindings *ServiceTokenBindingsSpec `json:"serviceTokenBindings,omitempty"`
+}
+
+type ServiceTokenBindingsSpec struct {
+	// Name of the managed policy
+	// +optional
+	// +kubebuilder:default="service token bindings"
+	PolicyName string `json:"policyName,omitempty"`
+
+	// Namespaces of the tokens permitted to bind; defaults to the namespace of the application
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Selects the tokens permitted to bind; defaults to all tokens in the permitted namespaces
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 }
 
 type CloudflareAccessPolicy struct {
@@ -158,11 +183,27 @@ type CloudflareAccessApplicationStatus struct {
 	CreatedAt           metav1.Time `json:"createdAt,omitempty"`
 	UpdatedAt           metav1.Time `json:"updatedAt,omitempty"`
 
+	// ServiceTokenBindings lists the service tokens binding the application and whether they are permitted
+	// +optional
+	ServiceTokenBindings []ServiceTokenBindingStatus `json:"serviceTokenBindings,omitempty"`
+
 	// Conditions store the status conditions of the CloudflareAccessApplication
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchMergeKey:"type" patchStrategy:"merge" protobuf:"bytes,1,rep,name=conditions"`
 }
 
+type ServiceTokenBindingStatus struct {
+	// Namespace of the CloudflareServiceToken
+	Namespace string `json:"namespace"`
+	// Name of the CloudflareServiceToken
+	Name string `json:"name"`
+	// Bound is true if the token is granted access by the managed policy
+	Bound bool `json:"bound"`
+	// Message explains why the binding isn't permitted
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
@@ -212,6 +253,59 @@ func (c *CloudflareAccessApplication) ToCloudflare() cloudflare.AccessApplicatio
 	return app
 }
 
+// PermitsServiceTokenBinding returns an empty string if the token may bind the application, or why it may not.
+func (c *CloudflareAccessApplication) PermitsServiceTokenBinding(token *CloudflareServiceToken) (string, error) {
+	bindings := c.Spec.ServiceTokenBindings
+	if bindings == nil {
+		return "the application doesn't permit service token bindings", nil
+	}
+
+	namespaces := bindings.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{c.Namespace}
+	}
+
+	if !slices.Contains(namespaces, token.Namespace) {
+		return "service tokens in namespace " + token.Namespace + " are not permitted", nil
+	}
+
+	if bindings.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(bindings.Selector)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid serviceTokenBindings selector")
+		}
+
+		if !selector.Matches(labels.Set(token.Labels)) {
+			return "the service token doesn't match the serviceTokenBindings selector", nil
+		}
+	}
+
+	return "", nil
+}
+
+// ServiceTokenBindingPolicy returns the managed policy granting access to the given token ids, or nil without ids.
+func (c *CloudflareAccessApplication) ServiceTokenBindingPolicy(tokenIDs []string) *CloudflareAccessPolicy {
+	if c.Spec.ServiceTokenBindings == nil || len(tokenIDs) == 0 {
+		return nil
+	}
+
+	name := c.Spec.ServiceTokenBindings.PolicyName
+	if name == "" {
+		name = "service token bindings"
+	}
+
+	tokens := make([]ServiceToken, 0, len(tokenIDs))
+	for _, id := range tokenIDs {
+		tokens = append(tokens, ServiceToken{Value: id})
+	}
+
+	return &CloudflareAccessPolicy{
+		Name:     name,
+		Decision: "non_identity",
+		Include:  []CloudFlareAccessGroupRule{{ServiceToken: tokens}},
+	}
+}
+
 // +kubebuilder:object:root=true
 
 // CloudflareAccessApplicationList contains a list of CloudflareAccessApplication.
@@ -0,0 +1,85 @@
+package v1alpha1_test
+
+import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("CloudflareAccessApplication", Label("CloudflareAccessApplication"), func() {
+	var app *v1alpha1.CloudflareAccessApplication
+
+	newToken := func(namespace string, labels map[string]string) *v1alpha1.CloudflareServiceToken {
+		return &v1alpha1.CloudflareServiceToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: namespace, Labels: labels},
+			Spec: v1alpha1.CloudflareServiceTokenSpec{
+				Applications: []v1alpha1.ApplicationReference{{Name: "app"}, {Namespace: "team-b", Name: "shared"}},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		app = &v1alpha1.CloudflareAccessApplication{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+		}
+	})
+
+	It("resolves the applications bound by a token in its namespace by default", func() {
+		token := newToken("team-a", nil)
+		Expect(token.BindsApplication("team-a", "app")).To(BeTrue())
+		Expect(token.BindsApplication("team-b", "shared")).To(BeTrue())
+		Expect(token.BindsApplication("team-b", "app")).To(BeFalse())
+	})
+
+	It("doesn't permit bindings by default", func() {
+		message, err := app.PermitsServiceTokenBinding(newToken("team-a", nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(message).ToNot(BeEmpty())
+	})
+
+	It("permits bindings from the namespace of the application by default", func() {
+		app.Spec.ServiceTokenBindings = &v1alpha1.ServiceTokenBindingsSpec{}
+
+		message, err := app.PermitsServiceTokenBinding(newToken("team-a", nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(message).To(BeEmpty())
+
+		message, err = app.PermitsServiceTokenBinding(newToken("team-b", nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(message).To(ContainSubstring("team-b"))
+	})
+
+	It("permits bindings from the listed namespaces matching the selector", func() {
+		app.Spec.ServiceTokenBindings = &v1alpha1.ServiceTokenBindingsSpec{
+			Namespaces: []string{"team-b"},
+			Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"access": "app"}},
+		}
+
+		message, err := app.PermitsServiceTokenBinding(newToken("team-b", map[string]string{"access": "app"}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(message).To(BeEmpty())
+
+		for _, token := range []*v1alpha1.CloudflareServiceToken{newToken("team-b", nil), newToken("team-a", map[string]string{"access": "app"})} {
+			message, err = app.PermitsServiceTokenBinding(token)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).ToNot(BeEmpty())
+		}
+	})
+
+	It("manages a non_identity policy including the bound tokens", func() {
+		Expect(app.ServiceTokenBindingPolicy([]string{"id"})).To(BeNil())
+
+		app.Spec.ServiceTokenBindings = &v1alpha1.ServiceTokenBindingsSpec{}
+		Expect(app.ServiceTokenBindingPolicy(nil)).To(BeNil())
+
+		policy := app.ServiceTokenBindingPolicy([]string{"id1", "id2"})
+		Expect(policy).ToNot(BeNil())
+		Expect(policy.Name).To(Equal("service token bindings"))
+		Expect(policy.Decision).To(Equal("non_identity"))
+
+		policies := v1alpha1.CloudflareAccessPolicyList{*policy}.ToCloudflare()
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].Include).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,235 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfapi"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/pkg/errors"
+)
+
+// UnsupportedRuleError is returned when cloudflare rules have no CloudFlareAccessGroupRule equivalent.
+// +kubebuilder:object:generate=false
+type UnsupportedRuleError struct {
+	RuleTypes []string
+}
+
+func (e *UnsupportedRuleError) Error() string {
+	return "unsupported cloudflare rule types: " + strings.Join(e.RuleTypes, ", ")
+}
+
+func (e *UnsupportedRuleError) add(ruleType string) {
+	for _, existing := range e.RuleTypes {
+		if existing == ruleType {
+			return
+		}
+	}
+
+	e.RuleTypes = append(e.RuleTypes, ruleType)
+}
+
+// NewCloudflareAccessGroupSpec converts a cloudflare access group back to its CR representation.
+// Unsupported rules are skipped and reported with an *UnsupportedRuleError.
+func NewCloudflareAccessGroupSpec(group cloudflare.AccessGroup) (CloudflareAccessGroupSpec, error) {
+	groups, err := FromCloudflareRuleGroups(group.Include, group.Exclude, group.Require)
+
+	return CloudflareAccessGroupSpec{
+		Name:    group.Name,
+		Include: groups[0],
+		Exclude: groups[1],
+		Require: groups[2],
+	}, err
+}
+
+// NewCloudflareAccessPolicy converts a cloudflare access policy back to its CR representation.
+// Unsupported rules are skipped and reported with an *UnsupportedRuleError.
+func NewCloudflareAccessPolicy(policy cloudflare.AccessPolicy) (CloudflareAccessPolicy, error) {
+	groups, err := FromCloudflareRuleGroups(policy.Include, policy.Exclude, policy.Require)
+
+	return CloudflareAccessPolicy{
+		Name:     policy.Name,
+		Decision: policy.Decision,
+		Include:  groups[0],
+		Exclude:  groups[1],
+		Require:  groups[2],
+	}, err
+}
+
+// FromCloudflareRuleGroups converts several rule lists at once; unsupported rule types of all lists are merged into a single error.
+func FromCloudflareRuleGroups(ruleGroups ...[]interface{}) ([][]CloudFlareAccessGroupRule, error) {
+	result := make([][]CloudFlareAccessGroupRule, 0, len(ruleGroups))
+	unsupported := &UnsupportedRuleError{}
+
+	for _, rules := range ruleGroups {
+		converted, err := FromCloudflareRules(rules)
+
+		var unsupportedErr *UnsupportedRuleError
+		if errors.As(err, &unsupportedErr) {
+			for _, ruleType := range unsupportedErr.RuleTypes {
+				unsupported.add(ruleType)
+			}
+		} else if err != nil {
+			return nil, err
+		}
+
+		result = append(result, converted)
+	}
+
+	if len(unsupported.RuleTypes) > 0 {
+		return result, unsupported
+	}
+
+	return result, nil
+}
+
+// FromCloudflareRules is the reverse of TransformCloudflareRuleFields. Every cloudflare rule becomes its own
+// CloudFlareAccessGroupRule so that the order of the rules is preserved. Rules can either be the typed
+// cloudflare structs or the generic maps returned by the cloudflare API.
+// Unsupported rules are skipped and reported with an *UnsupportedRuleError.
+func FromCloudflareRules(rules []interface{}) ([]CloudFlareAccessGroupRule, error) {
+	result := make([]CloudFlareAccessGroupRule, 0, len(rules))
+	unsupported := &UnsupportedRuleError{}
+
+	for _, rule := range rules {
+		raw, err := json.Marshal(rule)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to marshal cloudflare rule")
+		}
+
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal cloudflare rule")
+		}
+
+		if len(fields) != 1 {
+			ruleTypes := make([]string, 0, len(fields))
+			for ruleType := range fields {
+				ruleTypes = append(ruleTypes, ruleType)
+			}
+			sort.Strings(ruleTypes)
+			unsupported.add(strings.Join(ruleTypes, "+"))
+
+			continue
+		}
+
+		for ruleType := range fields {
+			converted, ok, err := decodeCloudflareRule(ruleType, raw)
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				unsupported.add(ruleType)
+
+				continue
+			}
+
+			result = append(result, converted)
+		}
+	}
+
+	if len(unsupported.RuleTypes) > 0 {
+		return result, unsupported
+	}
+
+	return result, nil
+}
+
+// nolint: cyclop,funlen
+func decodeCloudflareRule(ruleType string, raw []byte) (CloudFlareAccessGroupRule, bool, error) {
+	rule := CloudFlareAccessGroupRule{}
+	enabled := true
+
+	var err error
+
+	switch ruleType {
+	case "email":
+		value := cloudflare.AccessGroupEmail{}
+		err = json.Unmarshal(raw, &value)
+		rule.Emails = []string{value.Email.Email}
+	case "email_domain":
+		value := cloudflare.AccessGroupEmailDomain{}
+		err = json.Unmarshal(raw, &value)
+		rule.EmailDomains = []string{value.EmailDomain.Domain}
+	case "ip":
+		value := cloudflare.AccessGroupIP{}
+		err = json.Unmarshal(raw, &value)
+		rule.IPRanges = []string{value.IP.IP}
+	case "service_token":
+		value := cloudflare.AccessGroupServiceToken{}
+		err = json.Unmarshal(raw, &value)
+		rule.ServiceToken = []ServiceToken{{Value: value.ServiceToken.ID}}
+	case "any_valid_service_token":
+		rule.AnyAccessServiceToken = &enabled
+	case "everyone":
+		rule.Everyone = &enabled
+	case "certificate":
+		rule.ValidCertificate = &enabled
+	case "geo":
+		value := cloudflare.AccessGroupGeo{}
+		err = json.Unmarshal(raw, &value)
+		rule.Country = []string{value.Geo.CountryCode}
+	case "group":
+		value := cloudflare.AccessGroupAccessGroup{}
+		err = json.Unmarshal(raw, &value)
+		rule.AccessGroups = []AccessGroup{{Value: value.Group.ID}}
+	case "login_method":
+		value := cloudflare.AccessGroupLoginMethod{}
+		err = json.Unmarshal(raw, &value)
+		rule.LoginMethod = []string{value.LoginMethod.ID}
+	case "gsuite":
+		value := cloudflare.AccessGroupGSuite{}
+		err = json.Unmarshal(raw, &value)
+		rule.GoogleGroups = []GoogleGroup{{Email: value.Gsuite.Email, IdentityProviderID: value.Gsuite.IdentityProviderID}}
+	case "okta":
+		value := cloudflare.AccessGroupOkta{}
+		err = json.Unmarshal(raw, &value)
+		rule.OktaGroup = []OktaGroup{{Name: value.Okta.Name, IdentityProviderID: value.Okta.IdentityProviderID}}
+	case "oidc":
+		value := cfapi.AccessGroupOIDCClaim{}
+		err = json.Unmarshal(raw, &value)
+		rule.OIDCClaims = []OIDCClaim{{Name: value.OIDC.Name, Value: value.OIDC.Value, IdentityProviderID: value.OIDC.IdentityProviderID}}
+	case "device_posture":
+		value := cloudflare.AccessGroupDevicePosture{}
+		err = json.Unmarshal(raw, &value)
+		rule.DevicePosture = []DevicePosture{{Value: value.DevicePosture.ID}}
+	case "email_list":
+		value := cloudflare.AccessGroupEmailList{}
+		err = json.Unmarshal(raw, &value)
+		rule.EmailList = []List{{Value: value.EmailList.ID}}
+	case "ip_list":
+		value := cloudflare.AccessGroupIPList{}
+		err = json.Unmarshal(raw, &value)
+		rule.IPList = []List{{Value: value.IPList.ID}}
+	case "external_evaluation":
+		value := cloudflare.AccessGroupExternalEvaluation{}
+		err = json.Unmarshal(raw, &value)
+		rule.ExternalEvaluation = []ExternalEvaluation{{EvaluateURL: value.ExternalEvaluation.EvaluateURL, KeysURL: value.ExternalEvaluation.KeysURL}}
+	default:
+		return rule, false, nil
+	}
+
+	if err != nil {
+		return rule, false, errors.Wrapf(err, "unable to decode cloudflare %s rule", ruleType)
+	}
+
+	return rule, true, nil
+}
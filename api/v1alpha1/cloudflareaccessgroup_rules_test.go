@@ -0,0 +1,196 @@
+package v1alpha1_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing/quick"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfapi"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// randomCloudflareRule returns a random rule for every rule type supported by the operator.
+func randomCloudflareRule(rnd *rand.Rand) interface{} {
+	value := func() string {
+		return fmt.Sprintf("value-%d", rnd.Intn(1000))
+	}
+
+	builders := []func() interface{}{
+		func() interface{} { return cfapi.NewAccessGroupEmail(value() + "@domain.com") },
+		func() interface{} { return cfapi.NewAccessGroupEmailDomains(value() + ".com") },
+		func() interface{} { return cfapi.NewAccessGroupIP(fmt.Sprintf("10.0.%d.0/24", rnd.Intn(255))) },
+		func() interface{} { return cfapi.NewAccessGroupServiceToken(value()) },
+		func() interface{} { return cfapi.NewAccessGroupAnyValidServiceToken() },
+		func() interface{} { return cfapi.NewAccessGroupEveryone() },
+		func() interface{} { return cfapi.NewAccessGroupCertificate() },
+		func() interface{} { return cfapi.NewAccessGroupGeo(value()) },
+		func() interface{} { return cfapi.NewAccessGroupAccessGroup(value()) },
+		func() interface{} { return cfapi.NewAccessGroupLoginMethod(value()) },
+		func() interface{} { return cfapi.NewAccessGroupGSuite(value()+"@domain.com", value()) },
+		func() interface{} { return cfapi.NewAccessGroupOktaGroup(value(), value()) },
+		func() interface{} { return cfapi.NewAccessGroupOIDCClaim(value(), value(), value()) },
+		func() interface{} { return cfapi.NewAccessGroupDevicePosture(value()) },
+		func() interface{} { return cfapi.NewAccessGroupEmailList(value()) },
+		func() interface{} { return cfapi.NewAccessGroupIPList(value()) },
+		func() interface{} {
+			return cfapi.NewAccessGroupExternalEvaluation("https://"+value()+".com/", "https://"+value()+".com/keys")
+		},
+	}
+
+	return builders[rnd.Intn(len(builders))]()
+}
+
+// cloudflareRules implements quick.Generator to produce random cloudflare rule lists.
+type cloudflareRules []interface{}
+
+func (cloudflareRules) Generate(rnd *rand.Rand, size int) reflect.Value {
+	rules := make(cloudflareRules, rnd.Intn(size+1))
+	for i := range rules {
+		rules[i] = randomCloudflareRule(rnd)
+	}
+
+	return reflect.ValueOf(rules)
+}
+
+func toCloudflareRules(rules []v1alpha1.CloudFlareAccessGroupRule) []interface{} {
+	result := make([]interface{}, 0)
+	v1alpha1.CloudFlareAccessGroupRuleGroups{rules}.TransformCloudflareRuleFields([]*[]interface{}{&result})
+
+	return result
+}
+
+func mergeRules(rules []v1alpha1.CloudFlareAccessGroupRule) v1alpha1.CloudFlareAccessGroupRule {
+	merged := v1alpha1.CloudFlareAccessGroupRule{}
+	for _, rule := range rules {
+		merged.Emails = append(merged.Emails, rule.Emails...)
+		merged.EmailDomains = append(merged.EmailDomains, rule.EmailDomains...)
+		merged.IPRanges = append(merged.IPRanges, rule.IPRanges...)
+		merged.AccessGroups = append(merged.AccessGroups, rule.AccessGroups...)
+		merged.Country = append(merged.Country, rule.Country...)
+		merged.ServiceToken = append(merged.ServiceToken, rule.ServiceToken...)
+		merged.LoginMethod = append(merged.LoginMethod, rule.LoginMethod...)
+		merged.GoogleGroups = append(merged.GoogleGroups, rule.GoogleGroups...)
+		merged.OktaGroup = append(merged.OktaGroup, rule.OktaGroup...)
+		merged.OIDCClaims = append(merged.OIDCClaims, rule.OIDCClaims...)
+		merged.DevicePosture = append(merged.DevicePosture, rule.DevicePosture...)
+		merged.EmailList = append(merged.EmailList, rule.EmailList...)
+		merged.IPList = append(merged.IPList, rule.IPList...)
+		merged.ExternalEvaluation = append(merged.ExternalEvaluation, rule.ExternalEvaluation...)
+		if rule.Everyone != nil {
+			merged.Everyone = rule.Everyone
+		}
+		if rule.ValidCertificate != nil {
+			merged.ValidCertificate = rule.ValidCertificate
+		}
+		if rule.AnyAccessServiceToken != nil {
+			merged.AnyAccessServiceToken = rule.AnyAccessServiceToken
+		}
+	}
+
+	return merged
+}
+
+func asJSON(v interface{}) string {
+	out, err := json.Marshal(v)
+	Expect(err).ToNot(HaveOccurred())
+
+	return string(out)
+}
+
+var _ = Describe("Decoding cloudflare rules", Label("CloudflareAccessGroup"), func() {
+	It("round-trips cloudflare rules through the CR representation", func() {
+		property := func(rules cloudflareRules) bool {
+			decoded, err := v1alpha1.FromCloudflareRules(rules)
+			if err != nil {
+				return false
+			}
+
+			return asJSON(toCloudflareRules(decoded)) == asJSON([]interface{}(rules))
+		}
+
+		Expect(quick.Check(property, &quick.Config{MaxCount: 500})).To(Succeed())
+	})
+
+	It("round-trips the generic maps returned by the cloudflare API", func() {
+		property := func(rules cloudflareRules) bool {
+			generic := []interface{}{}
+			if err := json.Unmarshal([]byte(asJSON([]interface{}(rules))), &generic); err != nil {
+				return false
+			}
+
+			decoded, err := v1alpha1.FromCloudflareRules(generic)
+			if err != nil {
+				return false
+			}
+
+			return asJSON(toCloudflareRules(decoded)) == asJSON([]interface{}(rules))
+		}
+
+		Expect(quick.Check(property, &quick.Config{MaxCount: 500})).To(Succeed())
+	})
+
+	It("is stable when converting CR rules to cloudflare and back", func() {
+		property := func(rules cloudflareRules) bool {
+			decoded, err := v1alpha1.FromCloudflareRules(rules)
+			if err != nil {
+				return false
+			}
+
+			// merge all decoded rules into a single CR rule; the transformation must still be stable
+			merged := mergeRules(decoded)
+
+			expected := toCloudflareRules([]v1alpha1.CloudFlareAccessGroupRule{merged})
+			redecoded, err := v1alpha1.FromCloudflareRules(expected)
+			if err != nil {
+				return false
+			}
+
+			return asJSON(toCloudflareRules(redecoded)) == asJSON(expected)
+		}
+
+		Expect(quick.Check(property, &quick.Config{MaxCount: 500})).To(Succeed())
+	})
+
+	It("reports unsupported rule types", func() {
+		rules := []interface{}{
+			cfapi.NewAccessGroupEmail("test@domain.com"),
+			map[string]interface{}{
+				"saml": map[string]interface{}{
+					"attribute_name":  "group",
+					"attribute_value": "admins",
+				},
+			},
+			cloudflare.AccessGroupAzure{},
+		}
+
+		decoded, err := v1alpha1.FromCloudflareRules(rules)
+		Expect(decoded).To(Equal([]v1alpha1.CloudFlareAccessGroupRule{{Emails: []string{"test@domain.com"}}}))
+
+		var unsupportedErr *v1alpha1.UnsupportedRuleError
+		Expect(err).To(BeAssignableToTypeOf(unsupportedErr))
+		Expect(err.(*v1alpha1.UnsupportedRuleError).RuleTypes).To(Equal([]string{"saml", "azureAD"}))
+	})
+
+	It("decodes an access group into a CR spec", func() {
+		group := cloudflare.AccessGroup{
+			Name:    "test",
+			Include: []interface{}{cfapi.NewAccessGroupEmailDomains("domain.com")},
+			Exclude: []interface{}{cfapi.NewAccessGroupGeo("US")},
+			Require: []interface{}{cfapi.NewAccessGroupCertificate()},
+		}
+
+		spec, err := v1alpha1.NewCloudflareAccessGroupSpec(group)
+		Expect(err).ToNot(HaveOccurred())
+
+		accessGroup := &v1alpha1.CloudflareAccessGroup{Spec: spec}
+		Expect(accessGroup.ToCloudflare().Name).To(Equal("test"))
+		Expect(asJSON(accessGroup.ToCloudflare().Include)).To(Equal(asJSON(group.Include)))
+		Expect(asJSON(accessGroup.ToCloudflare().Exclude)).To(Equal(asJSON(group.Exclude)))
+		Expect(asJSON(accessGroup.ToCloudflare().Require)).To(Equal(asJSON(group.Require)))
+	})
+})
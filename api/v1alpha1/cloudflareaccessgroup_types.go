@@ -38,6 +38,24 @@ type CloudflareAccessGroupSpec struct {
 
 	// Rules evaluated with a NOT logical operator. To match the policy, a user cannot meet any of the Exclude rules.
 	Exclude []CloudFlareAccessGroupRule `json:"exclude,omitempty"`
+
+	// Maximum number of include rules of a single cloudflare access group. Larger include lists are split into
+	// managed child groups included by this group. Defaults to the cloudflare limit of 1000 rules
+	// +kubebuilder:validation:Minimum=2
+	// +optional
+	MaxRulesPerGroup *int `json:"maxRulesPerGroup,omitempty"`
+}
+
+// DefaultMaxRulesPerGroup is the maximum number of rules cloudflare accepts in a single access group.
+const DefaultMaxRulesPerGroup = 1000
+
+// GetMaxRulesPerGroup returns the maximum number of include rules of a single cloudflare access group.
+func (c CloudflareAccessGroupSpec) GetMaxRulesPerGroup() int {
+	if c.MaxRulesPerGroup == nil {
+		return DefaultMaxRulesPerGroup
+	}
+
+	return *c.MaxRulesPerGroup
 }
 
 func (c CloudflareAccessGroupSpec) GetInclude() []CloudFlareAccessGroupRule {
@@ -56,12 +74,21 @@ type CloudFlareAccessGroupRule struct {
 	// Matches a Specific email
 	Emails []string `json:"emails,omitempty"`
 
+	// Matches the emails read from ConfigMaps or Secrets
+	EmailsFrom []RuleValuesSource `json:"emailsFrom,omitempty"`
+
 	// Matches a specific email Domain
 	EmailDomains []string `json:"emailDomains,omitempty"`
 
+	// Matches the email domains read from ConfigMaps or Secrets
+	EmailDomainsFrom []RuleValuesSource `json:"emailDomainsFrom,omitempty"`
+
 	// Matches an IP CIDR block
 	IPRanges []string `json:"ipRanges,omitempty"`
 
+	// Matches the IP CIDR blocks read from ConfigMaps, Secrets, node external IPs or Service load balancer IPs
+	IPRangesFrom []RuleValuesSource `json:"ipRangesFrom,omitempty"`
+
 	// Reference to other access groups
 	AccessGroups []AccessGroup `json:"accessGroups,omitempty"`
 
@@ -94,6 +121,18 @@ type CloudFlareAccessGroupRule struct {
 
 	// OIDC Claims
 	OIDCClaims []OIDCClaim `json:"oidcClaims,omitempty"`
+
+	// Matches a device posture rule
+	DevicePosture []DevicePosture `json:"devicePosture,omitempty"`
+
+	// Matches any email in a list of type EMAIL
+	EmailList []List `json:"emailList,omitempty"`
+
+	// Matches any IP in a list of type IP
+	IPList []List `json:"ipList,omitempty"`
+
+	// Delegates the decision to an external HTTP endpoint
+	ExternalEvaluation []ExternalEvaluation `json:"externalEvaluation,omitempty"`
 }
 
 // CloudflareAccessGroupStatus defines the observed state of CloudflareAccessGroup.
@@ -107,11 +146,30 @@ type CloudflareAccessGroupStatus struct {
 	// Updated timestamp of the resource in Cloudflare
 	UpdatedAt metav1.Time `json:"updatedAt,omitempty"`
 
+	// Dependents are the access groups and applications referencing this resource
+	// +optional
+	Dependents []ResourceReference `json:"dependents,omitempty"`
+
+	// Shards are the managed child groups holding the include rules when they exceed maxRulesPerGroup
+	// +optional
+	Shards []AccessGroupShard `json:"shards,omitempty"`
+
 	// Conditions store the status conditions of the CloudflareAccessApplication
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchMergeKey:"type" patchStrategy:"merge" protobuf:"bytes,1,rep,name=conditions"`
 }
 
+type AccessGroupShard struct {
+	// Name of the child access group in Cloudflare
+	Name string `json:"name"`
+
+	// AccessGroupID is the ID of the child access group in Cloudflare
+	AccessGroupID string `json:"accessGroupId,omitempty"`
+
+	// RuleCount is the number of include rules held by the child access group
+	RuleCount int `json:"ruleCount"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
@@ -136,6 +194,14 @@ func (c *CloudflareAccessGroup) UnderDeletion() bool {
 	return !c.ObjectMeta.DeletionTimestamp.IsZero()
 }
 
+func (c *CloudflareAccessGroup) GetDependents() []ResourceReference {
+	return c.Status.Dependents
+}
+
+func (c *CloudflareAccessGroup) SetDependents(dependents []ResourceReference) {
+	c.Status.Dependents = dependents
+}
+
 func (c *CloudflareAccessGroup) ToCloudflare() cloudflare.AccessGroup {
 	accessGroup := cloudflare.AccessGroup{
 		Name:      c.Spec.Name,
@@ -219,6 +285,30 @@ func (c CloudFlareAccessGroupRuleGroups) TransformCloudflareRuleFields(managedCF
 			for _, oidcClaim := range field.OIDCClaims {
 				*managedCFFields[i] = append(*managedCFFields[i], cfapi.NewAccessGroupOIDCClaim(oidcClaim.Name, oidcClaim.Value, oidcClaim.IdentityProviderID))
 			}
+
+			for _, posture := range field.DevicePosture {
+				if posture.Value != "" {
+					*managedCFFields[i] = append(*managedCFFields[i], cfapi.NewAccessGroupDevicePosture(posture.Value))
+				}
+			}
+
+			for _, list := range field.EmailList {
+				if list.Value != "" {
+					*managedCFFields[i] = append(*managedCFFields[i], cfapi.NewAccessGroupEmailList(list.Value))
+				}
+			}
+
+			for _, list := range field.IPList {
+				if list.Value != "" {
+					*managedCFFields[i] = append(*managedCFFields[i], cfapi.NewAccessGroupIPList(list.Value))
+				}
+			}
+
+			for _, evaluation := range field.ExternalEvaluation {
+				if evaluation.EvaluateURL != "" {
+					*managedCFFields[i] = append(*managedCFFields[i], cfapi.NewAccessGroupExternalEvaluation(evaluation.EvaluateURL, evaluation.KeysURL))
+				}
+			}
 		}
 	}
 }
@@ -267,5 +267,73 @@ var _ = Describe("Creating a CloudflareAccessGroup", Label("CloudflareAccessGrou
 				}))
 			}
 		})
+
+		It("can export devicePosture to the cloudflare object", func() {
+			ids := []v1alpha1.DevicePosture{{Value: "first_posture_rule_id"}, {Value: "second_posture_rule_id"}}
+			accessRule.Spec.Include = []v1alpha1.CloudFlareAccessGroupRule{{
+				DevicePosture: ids},
+			}
+			for i, id := range ids {
+				Expect(accessRule.ToCloudflare().Include[i]).To(Equal(cloudflare.AccessGroupDevicePosture{
+					DevicePosture: struct {
+						ID string "json:\"integration_uid\""
+					}{
+						ID: id.Value,
+					},
+				}))
+			}
+		})
+
+		It("can export emailList to the cloudflare object", func() {
+			ids := []v1alpha1.List{{Value: "first_list_id"}, {Value: "second_list_id"}}
+			accessRule.Spec.Include = []v1alpha1.CloudFlareAccessGroupRule{{
+				EmailList: ids},
+			}
+			for i, id := range ids {
+				Expect(accessRule.ToCloudflare().Include[i]).To(Equal(cloudflare.AccessGroupEmailList{
+					EmailList: struct {
+						ID string "json:\"id\""
+					}{
+						ID: id.Value,
+					},
+				}))
+			}
+		})
+
+		It("can export ipList to the cloudflare object", func() {
+			ids := []v1alpha1.List{{Value: "first_list_id"}, {Value: "second_list_id"}}
+			accessRule.Spec.Include = []v1alpha1.CloudFlareAccessGroupRule{{
+				IPList: ids},
+			}
+			for i, id := range ids {
+				Expect(accessRule.ToCloudflare().Include[i]).To(Equal(cloudflare.AccessGroupIPList{
+					IPList: struct {
+						ID string "json:\"id\""
+					}{
+						ID: id.Value,
+					},
+				}))
+			}
+		})
+
+		It("can export externalEvaluation to the cloudflare object", func() {
+			evaluations := []v1alpha1.ExternalEvaluation{
+				{EvaluateURL: "https://authz.domain.com/", KeysURL: "https://authz.domain.com/keys"},
+				{ValueFrom: &v1alpha1.ExternalEvaluationReference{Hostname: "unresolved.domain.com"}},
+			}
+			accessRule.Spec.Include = []v1alpha1.CloudFlareAccessGroupRule{{
+				ExternalEvaluation: evaluations},
+			}
+			Expect(accessRule.ToCloudflare().Include).To(HaveLen(1))
+			Expect(accessRule.ToCloudflare().Include[0]).To(Equal(cloudflare.AccessGroupExternalEvaluation{
+				ExternalEvaluation: struct {
+					EvaluateURL string "json:\"evaluate_url\""
+					KeysURL     string "json:\"keys_url\""
+				}{
+					EvaluateURL: "https://authz.domain.com/",
+					KeysURL:     "https://authz.domain.com/keys",
+				},
+			}))
+		})
 	})
 })
@@ -0,0 +1,186 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CloudflareDevicePostureRuleSpec defines the desired state of CloudflareDevicePostureRule.
+type CloudflareDevicePostureRuleSpec struct {
+	// Name of the Cloudflare Device Posture Rule
+	Name string `json:"name"`
+
+	// The type of the device posture check
+	// ex: os_version, disk_encryption, firewall, serial_number
+	// +kubebuilder:validation:Enum=os_version;disk_encryption;firewall;serial_number
+	Type string `json:"type"`
+
+	// Description of the device posture rule
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// How often the WARP client should run the posture check. defaults to "5m"
+	// +optional
+	// +kubebuilder:default="5m"
+	Schedule string `json:"schedule,omitempty"`
+
+	// How long a posture check result is valid for. ex: "1h"
+	// +optional
+	Expiration string `json:"expiration,omitempty"`
+
+	// Operating systems the posture check applies to
+	// +optional
+	Match []DevicePostureRuleMatch `json:"match,omitempty"`
+
+	// Values the posture check is evaluated against
+	// +optional
+	Input DevicePostureRuleInput `json:"input,omitempty"`
+}
+
+type DevicePostureRuleMatch struct {
+	// Operating system the posture check applies to
+	// +kubebuilder:validation:Enum=windows;mac;linux;android;ios;chromeOS
+	Platform string `json:"platform"`
+}
+
+type DevicePostureRuleInput struct {
+	// os_version: Version of the operating system to compare against. ex: "10.0.19044"
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// os_version: Operator used to compare the version. ex: ">="
+	// +optional
+	// +kubebuilder:validation:Enum="<";"<=";">";">=";"=="
+	Operator string `json:"operator,omitempty"`
+
+	// os_version: Name of the linux distribution
+	// +optional
+	OsDistroName string `json:"osDistroName,omitempty"`
+
+	// os_version: Revision of the linux distribution
+	// +optional
+	OsDistroRevision string `json:"osDistroRevision,omitempty"`
+
+	// os_version: Additional version data. ex: the Rapid Security Response version on macOS
+	// +optional
+	OsVersionExtra string `json:"osVersionExtra,omitempty"`
+
+	// disk_encryption: Require all disks to be encrypted
+	// +optional
+	RequireAll *bool `json:"requireAll,omitempty"`
+
+	// disk_encryption: Disks that are required to be encrypted. ex: ["C"]
+	// +optional
+	CheckDisks []string `json:"checkDisks,omitempty"`
+
+	// firewall: Whether the firewall needs to be enabled
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// serial_number: ID of the list containing the allowed serial numbers
+	// +optional
+	ID string `json:"id,omitempty"`
+}
+
+// CloudflareDevicePostureRuleStatus defines the observed state of CloudflareDevicePostureRule.
+type CloudflareDevicePostureRuleStatus struct {
+	// DevicePostureRuleID is the ID of the reference in Cloudflare
+	DevicePostureRuleID string `json:"devicePostureRuleId,omitempty"`
+
+	// Dependents are the access groups and applications referencing this resource
+	// +optional
+	Dependents []ResourceReference `json:"dependents,omitempty"`
+
+	// Conditions store the status conditions of the CloudflareDevicePostureRule
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchMergeKey:"type" patchStrategy:"merge" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// CloudflareDevicePostureRule is the Schema for the cloudflaredeviceposturerules API.
+type CloudflareDevicePostureRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudflareDevicePostureRuleSpec   `json:"spec,omitempty"`
+	Status CloudflareDevicePostureRuleStatus `json:"status,omitempty"`
+}
+
+func (c *CloudflareDevicePostureRule) GetType() string {
+	return "CloudflareDevicePostureRule"
+}
+
+func (c *CloudflareDevicePostureRule) GetID() string {
+	return c.Status.DevicePostureRuleID
+}
+
+func (c *CloudflareDevicePostureRule) UnderDeletion() bool {
+	return !c.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+func (c *CloudflareDevicePostureRule) GetDependents() []ResourceReference {
+	return c.Status.Dependents
+}
+
+func (c *CloudflareDevicePostureRule) SetDependents(dependents []ResourceReference) {
+	c.Status.Dependents = dependents
+}
+
+func (c *CloudflareDevicePostureRule) ToCloudflare() cloudflare.DevicePostureRule {
+	match := make([]cloudflare.DevicePostureRuleMatch, 0, len(c.Spec.Match))
+	for _, m := range c.Spec.Match {
+		match = append(match, cloudflare.DevicePostureRuleMatch{Platform: m.Platform})
+	}
+
+	return cloudflare.DevicePostureRule{
+		ID:          c.Status.DevicePostureRuleID,
+		Name:        c.Spec.Name,
+		Type:        c.Spec.Type,
+		Description: c.Spec.Description,
+		Schedule:    c.Spec.Schedule,
+		Expiration:  c.Spec.Expiration,
+		Match:       match,
+		Input: cloudflare.DevicePostureRuleInput{
+			ID:               c.Spec.Input.ID,
+			Version:          c.Spec.Input.Version,
+			Operator:         c.Spec.Input.Operator,
+			OsDistroName:     c.Spec.Input.OsDistroName,
+			OsDistroRevision: c.Spec.Input.OsDistroRevision,
+			OSVersionExtra:   c.Spec.Input.OsVersionExtra,
+			RequireAll:       c.Spec.Input.RequireAll,
+			CheckDisks:       c.Spec.Input.CheckDisks,
+			Enabled:          c.Spec.Input.Enabled,
+		},
+	}
+}
+
+// +kubebuilder:object:root=true
+
+// CloudflareDevicePostureRuleList contains a list of CloudflareDevicePostureRule.
+type CloudflareDevicePostureRuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudflareDevicePostureRule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudflareDevicePostureRule{}, &CloudflareDevicePostureRuleList{})
+}
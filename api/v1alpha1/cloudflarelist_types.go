@@ -0,0 +1,145 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ListTypeEmail  = "EMAIL"
+	ListTypeIP     = "IP"
+	ListTypeDomain = "DOMAIN"
+	ListTypeSerial = "SERIAL"
+)
+
+// CloudflareListSpec defines the desired state of CloudflareList.
+type CloudflareListSpec struct {
+	// Name of the Cloudflare List
+	Name string `json:"name"`
+
+	// The type of values stored in the list
+	// ex: EMAIL, IP, DOMAIN, SERIAL
+	// +kubebuilder:validation:Enum=EMAIL;IP;DOMAIN;SERIAL
+	Type string `json:"type"`
+
+	// Description of the list
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Values stored in the list
+	// +optional
+	Items []string `json:"items,omitempty"`
+
+	// Sources to load additional values from. Values are separated by new lines;
+	// empty lines and lines starting with '#' are ignored
+	// +optional
+	ItemsFrom []ListItemsSource `json:"itemsFrom,omitempty"`
+}
+
+type ListItemsSource struct {
+	// Selects a key of a ConfigMap in the namespace of the CloudflareList
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// CloudflareListStatus defines the observed state of CloudflareList.
+type CloudflareListStatus struct {
+	// ListID is the ID of the reference in Cloudflare
+	ListID string `json:"listId,omitempty"`
+
+	// Creation timestamp of the resource in Cloudflare
+	CreatedAt metav1.Time `json:"createdAt,omitempty"`
+
+	// Updated timestamp of the resource in Cloudflare
+	UpdatedAt metav1.Time `json:"updatedAt,omitempty"`
+
+	// Number of values stored in the list
+	ItemCount int `json:"itemCount,omitempty"`
+
+	// Dependents are the access groups and applications referencing this resource
+	// +optional
+	Dependents []ResourceReference `json:"dependents,omitempty"`
+
+	// Conditions store the status conditions of the CloudflareList
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchMergeKey:"type" patchStrategy:"merge" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// CloudflareList is the Schema for the cloudflarelists API.
+type CloudflareList struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudflareListSpec   `json:"spec,omitempty"`
+	Status CloudflareListStatus `json:"status,omitempty"`
+}
+
+func (c *CloudflareList) GetType() string {
+	return "CloudflareList"
+}
+
+func (c *CloudflareList) GetID() string {
+	return c.Status.ListID
+}
+
+func (c *CloudflareList) UnderDeletion() bool {
+	return !c.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+func (c *CloudflareList) GetDependents() []ResourceReference {
+	return c.Status.Dependents
+}
+
+func (c *CloudflareList) SetDependents(dependents []ResourceReference) {
+	c.Status.Dependents = dependents
+}
+
+// ToCloudflare converts the list to its cloudflare representation using the already resolved items.
+func (c *CloudflareList) ToCloudflare(items []string) cloudflare.TeamsList {
+	list := cloudflare.TeamsList{
+		ID:          c.Status.ListID,
+		Name:        c.Spec.Name,
+		Type:        c.Spec.Type,
+		Description: c.Spec.Description,
+		Items:       make([]cloudflare.TeamsListItem, 0, len(items)),
+	}
+
+	for _, item := range items {
+		list.Items = append(list.Items, cloudflare.TeamsListItem{Value: item})
+	}
+
+	return list
+}
+
+// +kubebuilder:object:root=true
+
+// CloudflareListList contains a list of CloudflareList.
+type CloudflareListList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudflareList `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudflareList{}, &CloudflareListList{})
+}
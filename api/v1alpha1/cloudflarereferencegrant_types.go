@@ -0,0 +1,98 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CloudflareReferenceGrantSpec defines which namespaces are allowed to reference resources in the namespace of the grant.
+type CloudflareReferenceGrantSpec struct {
+	// From describes the trusted namespaces and kinds that can reference the resources described in To
+	// +kubebuilder:validation:MinItems=1
+	From []ReferenceGrantFrom `json:"from"`
+
+	// To describes the resources that may be referenced by the resources described in From
+	// +kubebuilder:validation:MinItems=1
+	To []ReferenceGrantTo `json:"to"`
+}
+
+type ReferenceGrantFrom struct {
+	// Kind of the referencing resource
+	// +kubebuilder:validation:Enum=CloudflareAccessGroup;CloudflareAccessApplication
+	Kind string `json:"kind"`
+
+	// Namespace of the referencing resource
+	Namespace string `json:"namespace"`
+}
+
+type ReferenceGrantTo struct {
+	// Kind of the referenced resource
+	// +kubebuilder:validation:Enum=CloudflareAccessGroup;CloudflareServiceToken;CloudflareDevicePostureRule;CloudflareList;ConfigMap;Service;Secret
+	Kind string `json:"kind"`
+
+	// Name of the referenced resource. When empty all resources of the kind may be referenced
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CloudflareReferenceGrant is the Schema for the cloudflarereferencegrants API.
+type CloudflareReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CloudflareReferenceGrantSpec `json:"spec,omitempty"`
+}
+
+// Permits returns true if the grant allows a fromKind in fromNamespace to reference the toKind named toName.
+func (c *CloudflareReferenceGrant) Permits(fromKind string, fromNamespace string, toKind string, toName string) bool {
+	fromAllowed := false
+	for _, from := range c.Spec.From {
+		if from.Kind == fromKind && from.Namespace == fromNamespace {
+			fromAllowed = true
+
+			break
+		}
+	}
+
+	if !fromAllowed {
+		return false
+	}
+
+	for _, to := range c.Spec.To {
+		if to.Kind == toKind && (to.Name == "" || to.Name == toName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// +kubebuilder:object:root=true
+
+// CloudflareReferenceGrantList contains a list of CloudflareReferenceGrant.
+type CloudflareReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudflareReferenceGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudflareReferenceGrant{}, &CloudflareReferenceGrantList{})
+}
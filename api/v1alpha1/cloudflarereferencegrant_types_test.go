@@ -0,0 +1,49 @@
+package v1alpha1_test
+
+import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudflareReferenceGrant", Label("CloudflareReferenceGrant"), func() {
+	var grant *v1alpha1.CloudflareReferenceGrant
+
+	BeforeEach(func() {
+		grant = &v1alpha1.CloudflareReferenceGrant{
+			Spec: v1alpha1.CloudflareReferenceGrantSpec{
+				From: []v1alpha1.ReferenceGrantFrom{
+					{Kind: "CloudflareAccessApplication", Namespace: "team-a"},
+				},
+				To: []v1alpha1.ReferenceGrantTo{
+					{Kind: "CloudflareServiceToken", Name: "shared-token"},
+					{Kind: "CloudflareAccessGroup"},
+				},
+			},
+		}
+	})
+
+	It("permits references from a trusted namespace and kind", func() {
+		Expect(grant.Permits("CloudflareAccessApplication", "team-a", "CloudflareServiceToken", "shared-token")).To(BeTrue())
+	})
+
+	It("permits any name when the name is omitted", func() {
+		Expect(grant.Permits("CloudflareAccessApplication", "team-a", "CloudflareAccessGroup", "any-group")).To(BeTrue())
+	})
+
+	It("rejects references to other names", func() {
+		Expect(grant.Permits("CloudflareAccessApplication", "team-a", "CloudflareServiceToken", "private-token")).To(BeFalse())
+	})
+
+	It("rejects references from other namespaces", func() {
+		Expect(grant.Permits("CloudflareAccessApplication", "team-b", "CloudflareServiceToken", "shared-token")).To(BeFalse())
+	})
+
+	It("rejects references from other kinds", func() {
+		Expect(grant.Permits("CloudflareAccessGroup", "team-a", "CloudflareServiceToken", "shared-token")).To(BeFalse())
+	})
+
+	It("rejects references to kinds that are not granted", func() {
+		Expect(grant.Permits("CloudflareAccessApplication", "team-a", "CloudflareList", "shared-token")).To(BeFalse())
+	})
+})
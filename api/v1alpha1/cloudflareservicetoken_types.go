@@ -17,8 +17,15 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cftypes"
 	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -31,21 +38,116 @@ type CloudflareServiceTokenSpec struct {
 	// Name of the Cloudflare Access Group
 	Name string `json:"name"`
 
-	// Time before the token should be automatically renewed. Defaults to "0"
+	// Lifetime of the token in Cloudflare as a duration (ex: "8760h") or "forever". Applied when the token
+	// is created and renewed. Defaults to the Cloudflare default of one year
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(forever|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+	Duration string `json:"duration,omitempty"`
+
+	// Time before the token should be automatically renewed as a duration (ex: "720h"). Defaults to "0"
 	// Automatically renewing a service token will change the service token value upon renewal.
 	// Tokens will get automatically renewed if the token is expired
 	// +optional
 	// +kubebuilder:default="0"
 	MinTimeBeforeRenewal string `json:"minTimeBeforeRenewal,omitempty"`
 
-	// Recreate the token if the secret with the service token value is missing or doesn't exist
+	// Strategy used to renew the token. Rotate (the default) rotates the client secret of the token in place.
+	// Overlap creates a successor token and keeps the previous token valid during the overlap window;
+	// the secret holds both credentials and policies referencing the token allow both tokens until the
+	// previous token is deleted at the end of the window
+	// +optional
+	// +kubebuilder:validation:Enum=Rotate;Overlap
+	// +kubebuilder:default=Rotate
+	RotationStrategy string `json:"rotationStrategy,omitempty"`
+
+	// Time the previous token stays valid after a renewal with the Overlap strategy as a duration. Defaults to "24h"
+	// +optional
+	// +kubebuilder:default="24h"
+	OverlapWindow string `json:"overlapWindow,omitempty"`
+
+	// Time before the expiry from which the token is reported as ExpiringSoon as a duration. Defaults to "168h"
+	// +optional
+	// +kubebuilder:default="168h"
+	ExpiryWarning string `json:"expiryWarning,omitempty"`
+
+	// Report the token as Stale when it wasn't used for this duration (ex: "2160h"). Disabled when empty
+	// +optional
+	StaleAfter string `json:"staleAfter,omitempty"`
+
+	// Delete the CloudflareServiceToken, and the token in Cloudflare, once it is Stale
+	// +optional
+	DeleteStale bool `json:"deleteStale,omitempty"`
+
+	// Recreate the token with fresh credentials if it was deleted in Cloudflare or if the secret with the
+	// service token value is missing. When disabled the resource is marked as Degraded instead. Defaults to true
+	// +optional
 	// +kubebuilder:default=true
-	RecreateMissing bool `json:"recreateMissing,omitempty"`
+	RecreateMissing *bool `json:"recreateMissing,omitempty"`
 
 	// Template to apply for the generated secret
 	// +optional
 	// +kubebuilder:default={"metadata": {}}
 	Template SecretTemplateSpec `json:"template,omitempty"`
+
+	// Replicate the generated secret into other namespaces
+	// +optional
+	Replication *SecretReplicationSpec `json:"replication,omitempty"`
+
+	// Restart the Deployments, StatefulSets and DaemonSets consuming the token when its credentials change.
+	// Workloads reading the secret through env or volumes, or labelled with
+	// cloudflare.zelic.io/restart-on-rotation=<name of the token>, are rolled by annotating their pod template
+	// +optional
+	RestartWorkloads bool `json:"restartWorkloads,omitempty"`
+
+	// Store the credentials in an external secret store instead of a kubernetes secret
+	// +optional
+	SecretStore *SecretStoreSpec `json:"secretStore,omitempty"`
+
+	// Applications the token is granted access to through a non_identity policy managed on each application.
+	// The application has to permit the binding with spec.serviceTokenBindings
+	// +optional
+	Applications []ApplicationReference `json:"applications,omitempty"`
+}
+
+type ApplicationReference struct {
+	// `namespace` is the namespace of the CloudflareAccessApplication; defaults to the namespace of the token.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// `name` is the name of the CloudflareAccessApplication.
+	// Required
+	Name string `json:"name"`
+}
+
+type SecretStoreSpec struct {
+	// Store the credentials in a HashiCorp Vault KV version 2 secrets engine
+	// +optional
+	Vault *VaultStoreSpec `json:"vault,omitempty"`
+}
+
+type VaultStoreSpec struct {
+	// Address of the Vault server, ex: https://vault.vault.svc:8200
+	Address string `json:"address"`
+
+	// Mount path of the KV version 2 secrets engine. Defaults to secret
+	// +optional
+	// +kubebuilder:default=secret
+	Mount string `json:"mount,omitempty"`
+
+	// Path of the secret in the secrets engine, ex: cloudflare/my-token
+	Path string `json:"path"`
+
+	// Key of a secret in the namespace of the service token holding the Vault token
+	TokenSecretRef corev1.SecretKeySelector `json:"tokenSecretRef"`
+}
+
+type SecretReplicationSpec struct {
+	// Namespaces to replicate the secret into
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Selects the namespaces to replicate the secret into
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
 }
 
 type SecretTemplateSpec struct {
@@ -67,6 +169,64 @@ type SecretTemplateSpec struct {
 	// +optional
 	// +kubebuilder:default=cloudflareClientId
 	ClientIDKey string `json:"clientIdKey,omitempty"`
+
+	// Additional entries of the secret rendered as Go templates, ex: "CF_ACCESS_CLIENT_ID={{ .ClientID }}".
+	// The templates can use .ClientID, .ClientSecret, .TokenID, .Name, .ExpiresAt and .Duration, and the json
+	// function to render a JSON string. The keys can't override the client id, client secret or token id keys,
+	// including the keys of the previous token
+	// +optional
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// RenderData renders the templated entries of the secret with the values of the token.
+func (s SecretTemplateSpec) RenderData(token cftypes.ExtendedServiceToken) (map[string][]byte, error) {
+	values := map[string]string{
+		"ClientID":     token.ClientID,
+		"ClientSecret": token.ClientSecret,
+		"TokenID":      token.ID,
+		"Name":         token.Name,
+		"ExpiresAt":    "",
+		"Duration":     token.Duration,
+	}
+
+	if token.ExpiresAt != nil && !token.ExpiresAt.IsZero() {
+		values["ExpiresAt"] = token.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	funcs := template.FuncMap{
+		"json": func(value string) (string, error) {
+			raw, err := json.Marshal(value)
+
+			return string(raw), errors.Wrap(err, "unable to marshal value")
+		},
+	}
+
+	reserved := map[string]bool{}
+	for _, key := range []string{s.ClientIDKey, s.ClientSecretKey, SecretKeyTokenID} {
+		reserved[key] = true
+		reserved[PreviousKey(key)] = true
+	}
+
+	data := make(map[string][]byte, len(s.Data))
+	for key, text := range s.Data {
+		if reserved[key] {
+			return nil, errors.Errorf("template data %q overrides the credentials of the secret", key)
+		}
+
+		tmpl, err := template.New(key).Funcs(funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid template data %q", key)
+		}
+
+		rendered := &bytes.Buffer{}
+		if err := tmpl.Execute(rendered, values); err != nil {
+			return nil, errors.Wrapf(err, "unable to render template data %q", key)
+		}
+
+		data[key] = rendered.Bytes()
+	}
+
+	return data, nil
 }
 
 // CloudflareServiceTokenStatus defines the observed state of CloudflareServiceToken.
@@ -83,11 +243,55 @@ type CloudflareServiceTokenStatus struct {
 	// Updated timestamp of the resource in Cloudflare
 	ExpiresAt metav1.Time `json:"expiresAt,omitempty"`
 
+	// Lifetime of the token in Cloudflare
+	// +optional
+	Duration string `json:"duration,omitempty"`
+
+	// Last time the token was used to authenticate in Cloudflare
+	// +optional
+	LastSeenAt *metav1.Time `json:"lastSeenAt,omitempty"`
+
+	// ID of the previous servicetoken in Cloudflare which stays valid until the end of the overlap window
+	// +optional
+	PreviousServiceTokenID string `json:"previousServiceTokenId,omitempty"`
+
+	// End of the overlap window; the previous token is deleted afterwards
+	// +optional
+	OverlapEndsAt *metav1.Time `json:"overlapEndsAt,omitempty"`
+
 	// SecretRef is the reference to the secret
 	// +optional
 	// +nullable
 	SecretRef *SecretRef `json:"secretRef,omitempty"`
 
+	// Replicas of the secret in other namespaces
+	// +optional
+	Replicas []SecretReplicaStatus `json:"replicas,omitempty"`
+
+	// Timestamp of the last automatic renewal of the token
+	// +optional
+	LastRotatedAt *metav1.Time `json:"lastRotatedAt,omitempty"`
+
+	// Number of times the token has been renewed
+	// +optional
+	RotationCount int `json:"rotationCount,omitempty"`
+
+	// Hash of the current credentials
+	// +optional
+	CredentialsHash string `json:"credentialsHash,omitempty"`
+
+	// Value of the last handled rotation-request annotation
+	// +optional
+	LastRotationRequest string `json:"lastRotationRequest,omitempty"`
+
+	// Timestamp of the last rotation on request, tracked apart from the renewals so that it doesn't postpone them
+	// +optional
+	LastRotationRequestedAt *metav1.Time `json:"lastRotationRequestedAt,omitempty"`
+
+	// Dependents are the access groups and applications referencing this resource
+	// +optional
+	Dependents []ResourceReference `json:"dependents,omitempty"`
+
 	// Conditions store the status conditions of the CloudflareAccessApplication
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchMergeKey:"type" patchStrategy:"merge" protobuf:"bytes,1,rep,name=conditions"`
@@ -101,6 +305,37 @@ type SecretRef struct {
 
 	// Key that stores the secret data.
 	ClientIDKey string `json:"clientIdKey,omitempty"`
+
+	// Sink holding the credentials, either Kubernetes or Vault
+	// +optional
+	Sink string `json:"sink,omitempty"`
+
+	// Location of the credentials in Vault
+	// +optional
+	Vault *VaultSecretRef `json:"vault,omitempty"`
+}
+
+type VaultSecretRef struct {
+	// Address of the Vault server
+	Address string `json:"address"`
+
+	// Mount path of the KV version 2 secrets engine
+	Mount string `json:"mount"`
+
+	// Path of the secret in the secrets engine
+	Path string `json:"path"`
+}
+
+type SecretReplicaStatus struct {
+	// Namespace of the replica
+	Namespace string `json:"namespace"`
+
+	// Synced is true if the replica holds the current secret data
+	Synced bool `json:"synced"`
+
+	// Reason the replica couldn't be synced
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -127,6 +362,237 @@ func (c *CloudflareServiceToken) UnderDeletion() bool {
 	return !c.ObjectMeta.DeletionTimestamp.IsZero()
 }
 
+func (c *CloudflareServiceToken) GetDependents() []ResourceReference {
+	return c.Status.Dependents
+}
+
+func (c *CloudflareServiceToken) SetDependents(dependents []ResourceReference) {
+	c.Status.Dependents = dependents
+}
+
+// ReplicaLabels returns the labels identifying the replicas of the secret of the token.
+func (c *CloudflareServiceToken) ReplicaLabels() map[string]string {
+	return map[string]string{
+		LabelReplicaOf:          c.Name,
+		LabelReplicaOfNamespace: c.Namespace,
+	}
+}
+
+// BindsApplication returns true if the token lists the application in spec.applications.
+func (c *CloudflareServiceToken) BindsApplication(namespace string, name string) bool {
+	for _, app := range c.Spec.Applications {
+		appNamespace := app.Namespace
+		if appNamespace == "" {
+			appNamespace = c.Namespace
+		}
+
+		if appNamespace == namespace && app.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Rotation strategies of service tokens.
+const (
+	RotationStrategyRotate  = "Rotate"
+	RotationStrategyOverlap = "Overlap"
+)
+
+// PreviousKey returns the key of the secret holding the value of key for the previous token.
+func PreviousKey(key string) string {
+	return "previous-" + key
+}
+
+// GetOverlapWindow returns the time the previous token stays valid after a renewal with the Overlap strategy.
+func (c CloudflareServiceTokenSpec) GetOverlapWindow() (time.Duration, error) {
+	if c.OverlapWindow == "" {
+		return 24 * time.Hour, nil
+	}
+
+	window, err := time.ParseDuration(c.OverlapWindow)
+
+	return window, errors.Wrap(err, "invalid overlapWindow")
+}
+
+// RotationRequested returns the value of the rotation-request annotation if it wasn't handled yet.
+func (c *CloudflareServiceToken) RotationRequested() (string, bool) {
+	request := c.Annotations[AnnotationRotationRequest]
+
+	return request, request != "" && request != c.Status.LastRotationRequest
+}
+
+// ExpiringAt returns the time from which the token is reported as ExpiringSoon.
+func (c *CloudflareServiceToken) ExpiringAt() (time.Time, error) {
+	warning := 168 * time.Hour
+	if c.Spec.ExpiryWarning != "" {
+		var err error
+		if warning, err = time.ParseDuration(c.Spec.ExpiryWarning); err != nil {
+			return time.Time{}, errors.Wrap(err, "invalid expiryWarning")
+		}
+	}
+
+	return c.Status.ExpiresAt.Add(-warning), nil
+}
+
+// StaleAt returns the time from which the token is reported as Stale; the token is stale once it wasn't used for
+// staleAfter since it was last seen, or since it was created if it was never used. Returns false if disabled.
+func (c *CloudflareServiceToken) StaleAt() (time.Time, bool, error) {
+	if c.Spec.StaleAfter == "" {
+		return time.Time{}, false, nil
+	}
+
+	staleAfter, err := time.ParseDuration(c.Spec.StaleAfter)
+	if err != nil {
+		return time.Time{}, false, errors.Wrap(err, "invalid staleAfter")
+	}
+
+	seenAt := c.Status.CreatedAt.Time
+	if c.Status.LastSeenAt != nil {
+		seenAt = c.Status.LastSeenAt.Time
+	}
+
+	if seenAt.IsZero() {
+		return time.Time{}, false, nil
+	}
+
+	return seenAt.Add(staleAfter), true, nil
+}
+
+// NextCheck returns when the status of the token has to be checked again: the next renewal, or earlier when
+// the token starts expiring, expires or becomes stale. Returns a zero time if there is nothing to check.
+func (c *CloudflareServiceToken) NextCheck(now time.Time) (time.Time, error) {
+	checks := []time.Time{}
+
+	if !c.Status.ExpiresAt.IsZero() {
+		nextRenewal, err := c.NextRenewal(now)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		expiringAt, err := c.ExpiringAt()
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		checks = append(checks, nextRenewal, expiringAt, c.Status.ExpiresAt.Time)
+	}
+
+	staleAt, enabled, err := c.StaleAt()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if enabled {
+		checks = append(checks, staleAt)
+	}
+
+	next := time.Time{}
+	for _, check := range checks {
+		if check.After(now) && (next.IsZero() || check.Before(next)) {
+			next = check
+		}
+	}
+
+	return next, nil
+}
+
+// GetRecreateMissing returns true if missing tokens or secrets should be recreated.
+func (c CloudflareServiceTokenSpec) GetRecreateMissing() bool {
+	return c.RecreateMissing == nil || *c.RecreateMissing
+}
+
+// RenewAt returns the time from which the token is inside its renewal window.
+func (c *CloudflareServiceToken) RenewAt() (time.Time, error) {
+	minTimeBeforeRenewal := time.Duration(0)
+	if c.Spec.MinTimeBeforeRenewal != "" {
+		var err error
+		if minTimeBeforeRenewal, err = time.ParseDuration(c.Spec.MinTimeBeforeRenewal); err != nil {
+			return time.Time{}, errors.Wrap(err, "invalid minTimeBeforeRenewal")
+		}
+	}
+
+	return c.Status.ExpiresAt.Add(-minTimeBeforeRenewal), nil
+}
+
+// NeedsRenewal returns true if the token is inside its renewal window and wasn't renewed in that window yet.
+// Expired tokens are always renewed.
+func (c *CloudflareServiceToken) NeedsRenewal(now time.Time) (bool, error) {
+	if c.Status.ExpiresAt.IsZero() {
+		return false, nil
+	}
+
+	renewAt, err := c.RenewAt()
+	if err != nil {
+		return false, err
+	}
+
+	if now.Before(renewAt) {
+		return false, nil
+	}
+
+	if !now.Before(c.Status.ExpiresAt.Time) {
+		return true, nil
+	}
+
+	// a token renewed inside its window has a minTimeBeforeRenewal exceeding its lifetime; wait for the expiry
+	return c.Status.LastRotatedAt == nil || c.Status.LastRotatedAt.Time.Before(renewAt), nil
+}
+
+// NextRenewal returns when the token has to be checked for renewal again, or the end of the overlap window if it's earlier.
+func (c *CloudflareServiceToken) NextRenewal(now time.Time) (time.Time, error) {
+	renewAt, err := c.RenewAt()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := c.Status.ExpiresAt.Time
+	if renewAt.After(now) {
+		next = renewAt
+	}
+
+	if c.Status.OverlapEndsAt != nil && c.Status.OverlapEndsAt.Time.Before(next) {
+		next = c.Status.OverlapEndsAt.Time
+	}
+
+	return next, nil
+}
+
+// Drift returns the differences between the spec and the token in Cloudflare, ex: `name "old" != "new"`.
+// The duration is only compared when it is set in the spec.
+func (c *CloudflareServiceToken) Drift(token cftypes.ExtendedServiceToken) []string {
+	drift := []string{}
+
+	if token.Name != c.Spec.Name {
+		drift = append(drift, fmt.Sprintf("name %q != %q", token.Name, c.Spec.Name))
+	}
+
+	if c.Spec.Duration != "" && !durationsEqual(token.Duration, c.Spec.Duration) {
+		drift = append(drift, fmt.Sprintf("duration %q != %q", token.Duration, c.Spec.Duration))
+	}
+
+	return drift
+}
+
+// durationsEqual compares two durations semantically, ex: "24h" and "1440m" are equal.
+func durationsEqual(first string, second string) bool {
+	if first == second {
+		return true
+	}
+
+	d1, err := time.ParseDuration(first)
+	if err != nil {
+		return false
+	}
+
+	d2, err := time.ParseDuration(second)
+	if err != nil {
+		return false
+	}
+
+	return d1 == d2
+}
+
 func (c CloudflareServiceToken) ToExtendedToken() cftypes.ExtendedServiceToken {
 	return cftypes.ExtendedServiceToken{
 		AccessServiceToken: cloudflare.AccessServiceToken{
@@ -135,6 +601,7 @@ func (c CloudflareServiceToken) ToExtendedToken() cftypes.ExtendedServiceToken {
 			ExpiresAt: &c.Status.ExpiresAt.Time,
 			ID:        c.Status.ServiceTokenID,
 			Name:      c.Spec.Name,
+			Duration:  c.Spec.Duration,
 		},
 	}
 }
@@ -0,0 +1,263 @@
+package v1alpha1_test
+
+import (
+	"time"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cftypes"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("CloudflareServiceToken", Label("CloudflareServiceToken"), func() {
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newToken := func(minTimeBeforeRenewal string) *v1alpha1.CloudflareServiceToken {
+		return &v1alpha1.CloudflareServiceToken{
+			Spec: v1alpha1.CloudflareServiceTokenSpec{
+				MinTimeBeforeRenewal: minTimeBeforeRenewal,
+			},
+			Status: v1alpha1.CloudflareServiceTokenStatus{
+				ExpiresAt: metav1.NewTime(expiresAt),
+			},
+		}
+	}
+
+	It("renews at expiry by default", func() {
+		for _, minTimeBeforeRenewal := range []string{"", "0"} {
+			renewAt, err := newToken(minTimeBeforeRenewal).RenewAt()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(renewAt).To(Equal(expiresAt))
+		}
+	})
+
+	It("renews minTimeBeforeRenewal before expiry", func() {
+		renewAt, err := newToken("720h").RenewAt()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(renewAt).To(Equal(expiresAt.Add(-720 * time.Hour)))
+	})
+
+	It("rejects invalid durations", func() {
+		_, err := newToken("30 days").RenewAt()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("needs renewal inside the renewal window", func() {
+		token := newToken("720h")
+
+		needsRenewal, err := token.NeedsRenewal(expiresAt.Add(-721 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(needsRenewal).To(BeFalse())
+
+		needsRenewal, err = token.NeedsRenewal(expiresAt.Add(-719 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(needsRenewal).To(BeTrue())
+	})
+
+	It("doesn't renew twice in the same window unless expired", func() {
+		token := newToken("720h")
+		lastRotatedAt := metav1.NewTime(expiresAt.Add(-700 * time.Hour))
+		token.Status.LastRotatedAt = &lastRotatedAt
+
+		needsRenewal, err := token.NeedsRenewal(expiresAt.Add(-600 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(needsRenewal).To(BeFalse())
+
+		needsRenewal, err = token.NeedsRenewal(expiresAt)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(needsRenewal).To(BeTrue())
+	})
+
+	It("never renews tokens without an expiry", func() {
+		needsRenewal, err := (&v1alpha1.CloudflareServiceToken{}).NeedsRenewal(time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(needsRenewal).To(BeFalse())
+	})
+
+	It("checks again at the start of the renewal window or at expiry", func() {
+		token := newToken("720h")
+
+		next, err := token.NextRenewal(expiresAt.Add(-1000 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(expiresAt.Add(-720 * time.Hour)))
+
+		next, err = token.NextRenewal(expiresAt.Add(-100 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(expiresAt))
+	})
+
+	It("checks the token again at the end of the overlap window", func() {
+		token := newToken("720h")
+		overlapEndsAt := metav1.NewTime(expiresAt.Add(-900 * time.Hour))
+		token.Status.OverlapEndsAt = &overlapEndsAt
+
+		next, err := token.NextRenewal(expiresAt.Add(-1000 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(overlapEndsAt.Time))
+	})
+
+	It("defaults the overlap window to a day", func() {
+		window, err := v1alpha1.CloudflareServiceTokenSpec{}.GetOverlapWindow()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(window).To(Equal(24 * time.Hour))
+
+		window, err = v1alpha1.CloudflareServiceTokenSpec{OverlapWindow: "1h"}.GetOverlapWindow()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(window).To(Equal(time.Hour))
+
+		_, err = v1alpha1.CloudflareServiceTokenSpec{OverlapWindow: "1 day"}.GetOverlapWindow()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("requests a rotation until the annotation is handled", func() {
+		token := newToken("")
+		_, requested := token.RotationRequested()
+		Expect(requested).To(BeFalse())
+
+		token.Annotations = map[string]string{v1alpha1.AnnotationRotationRequest: "2026-10-19T10:00:00Z"}
+		request, requested := token.RotationRequested()
+		Expect(requested).To(BeTrue())
+		Expect(request).To(Equal("2026-10-19T10:00:00Z"))
+
+		token.Status.LastRotationRequest = request
+		_, requested = token.RotationRequested()
+		Expect(requested).To(BeFalse())
+	})
+
+	It("recreates missing tokens unless disabled", func() {
+		recreateMissing := false
+		Expect(v1alpha1.CloudflareServiceTokenSpec{}.GetRecreateMissing()).To(BeTrue())
+		Expect(v1alpha1.CloudflareServiceTokenSpec{RecreateMissing: &recreateMissing}.GetRecreateMissing()).To(BeFalse())
+	})
+
+	It("reports name and duration drift", func() {
+		token := &v1alpha1.CloudflareServiceToken{
+			Spec: v1alpha1.CloudflareServiceTokenSpec{Name: "new", Duration: "24h"},
+		}
+
+		cfToken := cftypes.ExtendedServiceToken{AccessServiceToken: cloudflare.AccessServiceToken{Name: "old", Duration: "8760h"}}
+		Expect(token.Drift(cfToken)).To(Equal([]string{`name "old" != "new"`, `duration "8760h" != "24h"`}))
+
+		cfToken = cftypes.ExtendedServiceToken{AccessServiceToken: cloudflare.AccessServiceToken{Name: "new", Duration: "1440m"}}
+		Expect(token.Drift(cfToken)).To(BeEmpty())
+	})
+
+	It("ignores the duration when unset", func() {
+		token := &v1alpha1.CloudflareServiceToken{
+			Spec: v1alpha1.CloudflareServiceTokenSpec{Name: "new"},
+		}
+
+		cfToken := cftypes.ExtendedServiceToken{AccessServiceToken: cloudflare.AccessServiceToken{Name: "new", Duration: "8760h"}}
+		Expect(token.Drift(cfToken)).To(BeEmpty())
+	})
+
+	It("warns about the expiry a week before by default", func() {
+		expiringAt, err := newToken("").ExpiringAt()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(expiringAt).To(Equal(expiresAt.Add(-168 * time.Hour)))
+
+		token := newToken("")
+		token.Spec.ExpiryWarning = "1h"
+		expiringAt, err = token.ExpiringAt()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(expiringAt).To(Equal(expiresAt.Add(-time.Hour)))
+
+		token.Spec.ExpiryWarning = "1 day"
+		_, err = token.ExpiringAt()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("becomes stale staleAfter since it was last seen or created", func() {
+		token := newToken("")
+		_, enabled, err := token.StaleAt()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(enabled).To(BeFalse())
+
+		createdAt := metav1.NewTime(expiresAt.Add(-8760 * time.Hour))
+		token.Spec.StaleAfter = "720h"
+		token.Status.CreatedAt = createdAt
+		staleAt, enabled, err := token.StaleAt()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(enabled).To(BeTrue())
+		Expect(staleAt).To(Equal(createdAt.Add(720 * time.Hour)))
+
+		lastSeenAt := metav1.NewTime(expiresAt.Add(-1000 * time.Hour))
+		token.Status.LastSeenAt = &lastSeenAt
+		staleAt, _, err = token.StaleAt()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(staleAt).To(Equal(lastSeenAt.Add(720 * time.Hour)))
+	})
+
+	It("checks the token again when it starts expiring, expires or becomes stale", func() {
+		token := newToken("")
+		lastSeenAt := metav1.NewTime(expiresAt.Add(-2000 * time.Hour))
+		token.Spec.StaleAfter = "720h"
+		token.Status.LastSeenAt = &lastSeenAt
+
+		next, err := token.NextCheck(expiresAt.Add(-3000 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(lastSeenAt.Add(720 * time.Hour)))
+
+		next, err = token.NextCheck(expiresAt.Add(-1000 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(expiresAt.Add(-168 * time.Hour)))
+
+		next, err = token.NextCheck(expiresAt.Add(-100 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(Equal(expiresAt))
+
+		next, err = (&v1alpha1.CloudflareServiceToken{}).NextCheck(expiresAt)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(next).To(BeZero())
+	})
+
+	Describe("SecretTemplateSpec", func() {
+		cfToken := cftypes.ExtendedServiceToken{
+			AccessServiceToken: cloudflare.AccessServiceToken{ID: "id", Name: "my token", ClientID: "client.access", ExpiresAt: &expiresAt},
+			ClientSecret:       `se"cret`,
+		}
+
+		It("renders the templated entries", func() {
+			template := v1alpha1.SecretTemplateSpec{
+				ClientIDKey:     "cloudflareClientId",
+				ClientSecretKey: "cloudflareSecretKey",
+				Data: map[string]string{
+					".env": "CF_ACCESS_CLIENT_ID={{ .ClientID }}\nCF_ACCESS_CLIENT_SECRET={{ .ClientSecret }}",
+					"json": `{"id": {{ json .TokenID }}, "secret": {{ json .ClientSecret }}, "expiresAt": {{ json .ExpiresAt }}}`,
+				},
+			}
+
+			data, err := template.RenderData(cfToken)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data[".env"])).To(Equal("CF_ACCESS_CLIENT_ID=client.access\nCF_ACCESS_CLIENT_SECRET=se\"cret"))
+			Expect(string(data["json"])).To(MatchJSON(`{"id": "id", "secret": "se\"cret", "expiresAt": "2030-01-01T00:00:00Z"}`))
+		})
+
+		It("renders nothing by default", func() {
+			data, err := v1alpha1.SecretTemplateSpec{}.RenderData(cfToken)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(BeEmpty())
+		})
+
+		It("rejects invalid templates", func() {
+			for _, text := range []string{"{{ .ClientID", "{{ .Unknown }}"} {
+				_, err := v1alpha1.SecretTemplateSpec{Data: map[string]string{"key": text}}.RenderData(cfToken)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+
+		It("rejects entries overriding the credentials", func() {
+			for _, key := range []string{"cloudflareClientId", "cloudflareSecretKey", v1alpha1.SecretKeyTokenID, "previous-cloudflareClientId"} {
+				template := v1alpha1.SecretTemplateSpec{
+					ClientIDKey:     "cloudflareClientId",
+					ClientSecretKey: "cloudflareSecretKey",
+					Data:            map[string]string{key: "{{ .ClientID }}"},
+				}
+				_, err := template.RenderData(cfToken)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+})
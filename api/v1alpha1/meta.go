@@ -8,4 +8,33 @@ const (
 	LabelOwnedBy              = "cloudflare.zelic.io/owned-by"
 	FinalizerDeletion         = "cloudflare.zelic.io/finalizer"
 	AnnotationPreventDestroy  = "cloudflare.zelic.io/prevent-destroy"
+
+	AnnotationBlockDeletionWithDependents = "cloudflare.zelic.io/block-deletion-with-dependents"
+
+	// AnnotationRotationRequest requests the rotation of the client secret of a service token whenever its value changes
+	AnnotationRotationRequest = "cloudflare.zelic.io/rotation-request"
+
+	// LabelRestartOnRotation opts a workload into restarts when the credentials of the named service token change
+	LabelRestartOnRotation = "cloudflare.zelic.io/restart-on-rotation"
+	// AnnotationCredentialsHash is set on the pod template of restarted workloads
+	AnnotationCredentialsHash = "cloudflare.zelic.io/credentials-hash"
+
+	// LabelReplicaOf and LabelReplicaOfNamespace identify the service token of a replicated secret
+	LabelReplicaOf          = "cloudflare.zelic.io/replica-of"
+	LabelReplicaOfNamespace = "cloudflare.zelic.io/replica-of-namespace"
+
+	// SecretKeyTokenID is the key of the service token secret storing the token id
+	SecretKeyTokenID = "serviceTokenID"
+
+	// AnnotationResyncInterval overrides the interval the resource is reconciled again at to revert changes made in
+	// cloudflare, ex: 30m; 0 disables the resync
+	AnnotationResyncInterval = "cloudflare.zelic.io/resync-interval"
+
+	// LabelInjectServiceToken requests the injection of the credentials of the named service token into a Pod;
+	// the pod webhook only receives Pods with this label
+	LabelInjectServiceToken = "cloudflare.zelic.io/inject-service-token"
+	// AnnotationInjectMode selects how the credentials are injected, either as env vars (default) or as files
+	AnnotationInjectMode = "cloudflare.zelic.io/inject-mode"
+	// AnnotationInjectMountPath is the directory of the injected files
+	AnnotationInjectMountPath = "cloudflare.zelic.io/inject-mount-path"
 )
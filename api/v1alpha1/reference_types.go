@@ -1,6 +1,11 @@
 package v1alpha1
 
-import "k8s.io/apimachinery/pkg/types"
+import (
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
 
 type AccessGroup struct {
 	// Optional: no more than one of the following may be specified.
@@ -10,6 +15,9 @@ type AccessGroup struct {
 	// Source for the CloudflareAccessGroup's variable. Cannot be used if value is not empty.
 	// +optional
 	ValueFrom *AccessGroupReference `json:"valueFrom,omitempty" protobuf:"bytes,2,opt,name=valueFrom"`
+	// Selects every CloudflareAccessGroup matching the selector. Cannot be used if value or valueFrom is not empty.
+	// +optional
+	Selector *ResourceSelector `json:"selector,omitempty"`
 }
 
 type ServiceToken struct {
@@ -20,6 +28,42 @@ type ServiceToken struct {
 	// Source for the CloudflareServiceToken's variable. Cannot be used if value is not empty.
 	// +optional
 	ValueFrom *ServiceTokenReference `json:"valueFrom,omitempty" protobuf:"bytes,2,opt,name=valueFrom"`
+	// Selects every CloudflareServiceToken matching the selector. Cannot be used if value or valueFrom is not empty.
+	// +optional
+	Selector *ResourceSelector `json:"selector,omitempty"`
+}
+
+type DevicePosture struct {
+	// Optional: no more than one of the following may be specified.
+	// ID of the CloudflareDevicePostureRule
+	// +optional
+	Value string `json:"value,omitempty" protobuf:"bytes,1,opt,name=value"`
+	// Source for the CloudflareDevicePostureRule's variable. Cannot be used if value is not empty.
+	// +optional
+	ValueFrom *DevicePostureReference `json:"valueFrom,omitempty" protobuf:"bytes,2,opt,name=valueFrom"`
+}
+
+type List struct {
+	// Optional: no more than one of the following may be specified.
+	// ID of the CloudflareList
+	// +optional
+	Value string `json:"value,omitempty" protobuf:"bytes,1,opt,name=value"`
+	// Source for the CloudflareList's variable. Cannot be used if value is not empty.
+	// +optional
+	ValueFrom *ListReference `json:"valueFrom,omitempty" protobuf:"bytes,2,opt,name=valueFrom"`
+}
+
+type ExternalEvaluation struct {
+	// Optional: no more than one of the following may be specified.
+	// URL of the endpoint that evaluates access
+	// +optional
+	EvaluateURL string `json:"evaluateUrl,omitempty"`
+	// URL of the endpoint serving the public keys used to verify the evaluation response
+	// +optional
+	KeysURL string `json:"keysUrl,omitempty"`
+	// Source for the evaluation endpoint. Cannot be used if evaluateUrl is not empty.
+	// +optional
+	ValueFrom *ExternalEvaluationReference `json:"valueFrom,omitempty"`
 }
 
 type GoogleGroup struct {
@@ -70,3 +114,142 @@ type ServiceTokenReference struct {
 func (g *ServiceTokenReference) ToNamespacedName() types.NamespacedName {
 	return types.NamespacedName{Namespace: g.Namespace, Name: g.Name}
 }
+
+type DevicePostureReference struct {
+	// `namespace` is the namespace of the DevicePostureRule.
+	// Required
+	Namespace string `json:"namespace" protobuf:"bytes,1,opt,name=namespace"`
+	// `name` is the name of the DevicePostureRule.
+	// Required
+	Name string `json:"name" protobuf:"bytes,2,opt,name=name"`
+}
+
+func (g *DevicePostureReference) ToNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: g.Namespace, Name: g.Name}
+}
+
+type ListReference struct {
+	// `namespace` is the namespace of the List.
+	// Required
+	Namespace string `json:"namespace" protobuf:"bytes,1,opt,name=namespace"`
+	// `name` is the name of the List.
+	// Required
+	Name string `json:"name" protobuf:"bytes,2,opt,name=name"`
+}
+
+func (g *ListReference) ToNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: g.Namespace, Name: g.Name}
+}
+
+type ExternalEvaluationReference struct {
+	// Service serving the evaluation endpoint
+	Service ServiceReference `json:"service"`
+	// Public hostname that exposes the Service
+	Hostname string `json:"hostname"`
+	// Path of the evaluation endpoint; defaults to "/"
+	// +optional
+	EvaluatePath string `json:"evaluatePath,omitempty"`
+	// Path of the keys endpoint; defaults to "/keys"
+	// +optional
+	KeysPath string `json:"keysPath,omitempty"`
+}
+
+type ServiceReference struct {
+	// `namespace` is the namespace of the Service.
+	// Required
+	Namespace string `json:"namespace" protobuf:"bytes,1,opt,name=namespace"`
+	// `name` is the name of the Service.
+	// Required
+	Name string `json:"name" protobuf:"bytes,2,opt,name=name"`
+}
+
+func (g *ServiceReference) ToNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: g.Namespace, Name: g.Name}
+}
+
+// RuleValuesSource populates the values of a rule from another resource. Only one of the sources may be specified.
+type RuleValuesSource struct {
+	// Selects a key of a ConfigMap; one value per line
+	// +optional
+	ConfigMapKeyRef *KeyReference `json:"configMapKeyRef,omitempty"`
+	// Selects a key of a Secret; one value per line
+	// +optional
+	SecretKeyRef *KeyReference `json:"secretKeyRef,omitempty"`
+	// Selects the external IPs of the nodes matching the label selector; an empty selector selects all nodes.
+	// Only supported by ipRangesFrom
+	// +optional
+	NodeExternalIPs *metav1.LabelSelector `json:"nodeExternalIPs,omitempty"`
+	// Selects the ingress IPs of a Service of type LoadBalancer.
+	// Only supported by ipRangesFrom
+	// +optional
+	ServiceLoadBalancer *ServiceReference `json:"serviceLoadBalancer,omitempty"`
+}
+
+type KeyReference struct {
+	// `namespace` is the namespace of the ConfigMap or Secret.
+	// Required
+	Namespace string `json:"namespace"`
+	// `name` is the name of the ConfigMap or Secret.
+	// Required
+	Name string `json:"name"`
+	// `key` to select; values are separated by new lines, lines starting with # are ignored.
+	// Required
+	Key string `json:"key"`
+	// Specify whether the ConfigMap or Secret and its key may be missing
+	// +optional
+	Optional *bool `json:"optional,omitempty"`
+}
+
+func (g *KeyReference) ToNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: g.Namespace, Name: g.Name}
+}
+
+// ResourceReference identifies a resource that references another resource.
+type ResourceReference struct {
+	// Kind of the resource
+	Kind string `json:"kind"`
+	// Namespace of the resource
+	Namespace string `json:"namespace"`
+	// Name of the resource
+	Name string `json:"name"`
+}
+
+type ResourceSelector struct {
+	// `namespace` is the namespace of the selected resources; defaults to the namespace of the referencing resource.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	metav1.LabelSelector `json:",inline"`
+}
+
+// GetNamespace returns the namespace the selector applies to.
+func (s *ResourceSelector) GetNamespace(defaultNamespace string) string {
+	if s.Namespace != "" {
+		return s.Namespace
+	}
+
+	return defaultNamespace
+}
+
+// Matches returns true if the resource with the given namespace and labels is selected.
+func (s *ResourceSelector) Matches(defaultNamespace string, namespace string, objLabels map[string]string) (bool, error) {
+	if s.GetNamespace(defaultNamespace) != namespace {
+		return false, nil
+	}
+
+	selector, err := s.ToSelector()
+	if err != nil {
+		return false, err
+	}
+
+	return selector.Matches(labels.Set(objLabels)), nil
+}
+
+func (s *ResourceSelector) ToSelector() (labels.Selector, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&s.LabelSelector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid label selector")
+	}
+
+	return selector, nil
+}
@@ -0,0 +1,47 @@
+package v1alpha1_test
+
+import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("ResourceSelector", Label("ResourceSelector"), func() {
+	It("matches resources with the selected labels in the default namespace", func() {
+		selector := &v1alpha1.ResourceSelector{
+			LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+		}
+
+		Expect(selector.Matches("default", "default", map[string]string{"team": "payments", "tier": "api"})).To(BeTrue())
+		Expect(selector.Matches("default", "default", map[string]string{"team": "billing"})).To(BeFalse())
+		Expect(selector.Matches("default", "other", map[string]string{"team": "payments"})).To(BeFalse())
+	})
+
+	It("matches resources in the selected namespace", func() {
+		selector := &v1alpha1.ResourceSelector{
+			Namespace: "shared",
+			LabelSelector: metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "team", Operator: metav1.LabelSelectorOpIn, Values: []string{"payments", "billing"}},
+				},
+			},
+		}
+
+		Expect(selector.Matches("default", "shared", map[string]string{"team": "billing"})).To(BeTrue())
+		Expect(selector.Matches("default", "default", map[string]string{"team": "billing"})).To(BeFalse())
+	})
+
+	It("returns an error for an invalid selector", func() {
+		selector := &v1alpha1.ResourceSelector{
+			LabelSelector: metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "team", Operator: "Unknown"},
+				},
+			},
+		}
+
+		_, err := selector.Matches("default", "default", map[string]string{})
+		Expect(err).To(HaveOccurred())
+	})
+})
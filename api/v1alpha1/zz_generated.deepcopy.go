@@ -21,6 +21,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -33,6 +34,11 @@ func (in *AccessGroup) DeepCopyInto(out *AccessGroup) {
 		*out = new(AccessGroupReference)
 		**out = **in
 	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(ResourceSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessGroup.
@@ -60,6 +66,36 @@ func (in *AccessGroupReference) DeepCopy() *AccessGroupReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessGroupShard) DeepCopyInto(out *AccessGroupShard) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessGroupShard.
+func (in *AccessGroupShard) DeepCopy() *AccessGroupShard {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessGroupShard)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationReference) DeepCopyInto(out *ApplicationReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationReference.
+func (in *ApplicationReference) DeepCopy() *ApplicationReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudFlareAccessGroupRule) DeepCopyInto(out *CloudFlareAccessGroupRule) {
 	*out = *in
@@ -68,16 +104,37 @@ func (in *CloudFlareAccessGroupRule) DeepCopyInto(out *CloudFlareAccessGroupRule
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.EmailsFrom != nil {
+		in, out := &in.EmailsFrom, &out.EmailsFrom
+		*out = make([]RuleValuesSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.EmailDomains != nil {
 		in, out := &in.EmailDomains, &out.EmailDomains
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.EmailDomainsFrom != nil {
+		in, out := &in.EmailDomainsFrom, &out.EmailDomainsFrom
+		*out = make([]RuleValuesSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.IPRanges != nil {
 		in, out := &in.IPRanges, &out.IPRanges
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.IPRangesFrom != nil {
+		in, out := &in.IPRangesFrom, &out.IPRangesFrom
+		*out = make([]RuleValuesSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.AccessGroups != nil {
 		in, out := &in.AccessGroups, &out.AccessGroups
 		*out = make([]AccessGroup, len(*in))
@@ -137,6 +194,34 @@ func (in *CloudFlareAccessGroupRule) DeepCopyInto(out *CloudFlareAccessGroupRule
 		*out = make([]OIDCClaim, len(*in))
 		copy(*out, *in)
 	}
+	if in.DevicePosture != nil {
+		in, out := &in.DevicePosture, &out.DevicePosture
+		*out = make([]DevicePosture, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EmailList != nil {
+		in, out := &in.EmailList, &out.EmailList
+		*out = make([]List, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IPList != nil {
+		in, out := &in.IPList, &out.IPList
+		*out = make([]List, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExternalEvaluation != nil {
+		in, out := &in.ExternalEvaluation, &out.ExternalEvaluation
+		*out = make([]ExternalEvaluation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudFlareAccessGroupRule.
@@ -270,6 +355,11 @@ func (in *CloudflareAccessApplicationSpec) DeepCopyInto(out *CloudflareAccessApp
 		*out = new(bool)
 		**out = **in
 	}
+	if in.ServiceTokenBindings != nil {
+		in, out := &in.ServiceTokenBindings, &out.ServiceTokenBindings
+		*out = new(ServiceTokenBindingsSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareAccessApplicationSpec.
@@ -287,6 +377,11 @@ func (in *CloudflareAccessApplicationStatus) DeepCopyInto(out *CloudflareAccessA
 	*out = *in
 	in.CreatedAt.DeepCopyInto(&out.CreatedAt)
 	in.UpdatedAt.DeepCopyInto(&out.UpdatedAt)
+	if in.ServiceTokenBindings != nil {
+		in, out := &in.ServiceTokenBindings, &out.ServiceTokenBindings
+		*out = make([]ServiceTokenBindingStatus, len(*in))
+		copy(*out, *in)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -389,6 +484,11 @@ func (in *CloudflareAccessGroupSpec) DeepCopyInto(out *CloudflareAccessGroupSpec
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.MaxRulesPerGroup != nil {
+		in, out := &in.MaxRulesPerGroup, &out.MaxRulesPerGroup
+		*out = new(int)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareAccessGroupSpec.
@@ -406,6 +506,16 @@ func (in *CloudflareAccessGroupStatus) DeepCopyInto(out *CloudflareAccessGroupSt
 	*out = *in
 	in.CreatedAt.DeepCopyInto(&out.CreatedAt)
 	in.UpdatedAt.DeepCopyInto(&out.UpdatedAt)
+	if in.Dependents != nil {
+		in, out := &in.Dependents, &out.Dependents
+		*out = make([]ResourceReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Shards != nil {
+		in, out := &in.Shards, &out.Shards
+		*out = make([]AccessGroupShard, len(*in))
+		copy(*out, *in)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -483,7 +593,7 @@ func (in CloudflareAccessPolicyList) DeepCopy() CloudflareAccessPolicyList {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudflareServiceToken) DeepCopyInto(out *CloudflareServiceToken) {
+func (in *CloudflareDevicePostureRule) DeepCopyInto(out *CloudflareDevicePostureRule) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -491,18 +601,18 @@ func (in *CloudflareServiceToken) DeepCopyInto(out *CloudflareServiceToken) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareServiceToken.
-func (in *CloudflareServiceToken) DeepCopy() *CloudflareServiceToken {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareDevicePostureRule.
+func (in *CloudflareDevicePostureRule) DeepCopy() *CloudflareDevicePostureRule {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudflareServiceToken)
+	out := new(CloudflareDevicePostureRule)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudflareServiceToken) DeepCopyObject() runtime.Object {
+func (in *CloudflareDevicePostureRule) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -510,31 +620,31 @@ func (in *CloudflareServiceToken) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudflareServiceTokenList) DeepCopyInto(out *CloudflareServiceTokenList) {
+func (in *CloudflareDevicePostureRuleList) DeepCopyInto(out *CloudflareDevicePostureRuleList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]CloudflareServiceToken, len(*in))
+		*out = make([]CloudflareDevicePostureRule, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareServiceTokenList.
-func (in *CloudflareServiceTokenList) DeepCopy() *CloudflareServiceTokenList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareDevicePostureRuleList.
+func (in *CloudflareDevicePostureRuleList) DeepCopy() *CloudflareDevicePostureRuleList {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudflareServiceTokenList)
+	out := new(CloudflareDevicePostureRuleList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudflareServiceTokenList) DeepCopyObject() runtime.Object {
+func (in *CloudflareDevicePostureRuleList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -542,31 +652,33 @@ func (in *CloudflareServiceTokenList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudflareServiceTokenSpec) DeepCopyInto(out *CloudflareServiceTokenSpec) {
+func (in *CloudflareDevicePostureRuleSpec) DeepCopyInto(out *CloudflareDevicePostureRuleSpec) {
 	*out = *in
-	in.Template.DeepCopyInto(&out.Template)
+	if in.Match != nil {
+		in, out := &in.Match, &out.Match
+		*out = make([]DevicePostureRuleMatch, len(*in))
+		copy(*out, *in)
+	}
+	in.Input.DeepCopyInto(&out.Input)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareServiceTokenSpec.
-func (in *CloudflareServiceTokenSpec) DeepCopy() *CloudflareServiceTokenSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareDevicePostureRuleSpec.
+func (in *CloudflareDevicePostureRuleSpec) DeepCopy() *CloudflareDevicePostureRuleSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudflareServiceTokenSpec)
+	out := new(CloudflareDevicePostureRuleSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudflareServiceTokenStatus) DeepCopyInto(out *CloudflareServiceTokenStatus) {
+func (in *CloudflareDevicePostureRuleStatus) DeepCopyInto(out *CloudflareDevicePostureRuleStatus) {
 	*out = *in
-	in.CreatedAt.DeepCopyInto(&out.CreatedAt)
-	in.UpdatedAt.DeepCopyInto(&out.UpdatedAt)
-	in.ExpiresAt.DeepCopyInto(&out.ExpiresAt)
-	if in.SecretRef != nil {
-		in, out := &in.SecretRef, &out.SecretRef
-		*out = new(SecretRef)
-		**out = **in
+	if in.Dependents != nil {
+		in, out := &in.Dependents, &out.Dependents
+		*out = make([]ResourceReference, len(*in))
+		copy(*out, *in)
 	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -577,124 +689,923 @@ func (in *CloudflareServiceTokenStatus) DeepCopyInto(out *CloudflareServiceToken
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareServiceTokenStatus.
-func (in *CloudflareServiceTokenStatus) DeepCopy() *CloudflareServiceTokenStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareDevicePostureRuleStatus.
+func (in *CloudflareDevicePostureRuleStatus) DeepCopy() *CloudflareDevicePostureRuleStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudflareServiceTokenStatus)
+	out := new(CloudflareDevicePostureRuleStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GoogleGroup) DeepCopyInto(out *GoogleGroup) {
+func (in *CloudflareList) DeepCopyInto(out *CloudflareList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GoogleGroup.
-func (in *GoogleGroup) DeepCopy() *GoogleGroup {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareList.
+func (in *CloudflareList) DeepCopy() *CloudflareList {
 	if in == nil {
 		return nil
 	}
-	out := new(GoogleGroup)
+	out := new(CloudflareList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCClaim) DeepCopyInto(out *OIDCClaim) {
+func (in *CloudflareListList) DeepCopyInto(out *CloudflareListList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CloudflareList, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClaim.
-func (in *OIDCClaim) DeepCopy() *OIDCClaim {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareListList.
+func (in *CloudflareListList) DeepCopy() *CloudflareListList {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCClaim)
+	out := new(CloudflareListList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareListList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OktaGroup) DeepCopyInto(out *OktaGroup) {
+func (in *CloudflareListSpec) DeepCopyInto(out *CloudflareListSpec) {
 	*out = *in
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ItemsFrom != nil {
+		in, out := &in.ItemsFrom, &out.ItemsFrom
+		*out = make([]ListItemsSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OktaGroup.
-func (in *OktaGroup) DeepCopy() *OktaGroup {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareListSpec.
+func (in *CloudflareListSpec) DeepCopy() *CloudflareListSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(OktaGroup)
+	out := new(CloudflareListSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SecretRef) DeepCopyInto(out *SecretRef) {
+func (in *CloudflareListStatus) DeepCopyInto(out *CloudflareListStatus) {
 	*out = *in
-	out.LocalObjectReference = in.LocalObjectReference
+	in.CreatedAt.DeepCopyInto(&out.CreatedAt)
+	in.UpdatedAt.DeepCopyInto(&out.UpdatedAt)
+	if in.Dependents != nil {
+		in, out := &in.Dependents, &out.Dependents
+		*out = make([]ResourceReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretRef.
-func (in *SecretRef) DeepCopy() *SecretRef {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareListStatus.
+func (in *CloudflareListStatus) DeepCopy() *CloudflareListStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(SecretRef)
+	out := new(CloudflareListStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SecretTemplateSpec) DeepCopyInto(out *SecretTemplateSpec) {
+func (in *CloudflareReferenceGrant) DeepCopyInto(out *CloudflareReferenceGrant) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretTemplateSpec.
-func (in *SecretTemplateSpec) DeepCopy() *SecretTemplateSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareReferenceGrant.
+func (in *CloudflareReferenceGrant) DeepCopy() *CloudflareReferenceGrant {
 	if in == nil {
 		return nil
 	}
-	out := new(SecretTemplateSpec)
+	out := new(CloudflareReferenceGrant)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareReferenceGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ServiceToken) DeepCopyInto(out *ServiceToken) {
+func (in *CloudflareReferenceGrantList) DeepCopyInto(out *CloudflareReferenceGrantList) {
 	*out = *in
-	if in.ValueFrom != nil {
-		in, out := &in.ValueFrom, &out.ValueFrom
-		*out = new(ServiceTokenReference)
-		**out = **in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CloudflareReferenceGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceToken.
-func (in *ServiceToken) DeepCopy() *ServiceToken {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareReferenceGrantList.
+func (in *CloudflareReferenceGrantList) DeepCopy() *CloudflareReferenceGrantList {
 	if in == nil {
 		return nil
 	}
-	out := new(ServiceToken)
+	out := new(CloudflareReferenceGrantList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareReferenceGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ServiceTokenReference) DeepCopyInto(out *ServiceTokenReference) {
+func (in *CloudflareReferenceGrantSpec) DeepCopyInto(out *CloudflareReferenceGrantSpec) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]ReferenceGrantFrom, len(*in))
+		copy(*out, *in)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]ReferenceGrantTo, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareReferenceGrantSpec.
+func (in *CloudflareReferenceGrantSpec) DeepCopy() *CloudflareReferenceGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareReferenceGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareServiceToken) DeepCopyInto(out *CloudflareServiceToken) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceTokenReference.
-func (in *ServiceTokenReference) DeepCopy() *ServiceTokenReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareServiceToken.
+func (in *CloudflareServiceToken) DeepCopy() *CloudflareServiceToken {
 	if in == nil {
 		return nil
 	}
-	out := new(ServiceTokenReference)
+	out := new(CloudflareServiceToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareServiceToken) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareServiceTokenList) DeepCopyInto(out *CloudflareServiceTokenList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CloudflareServiceToken, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareServiceTokenList.
+func (in *CloudflareServiceTokenList) DeepCopy() *CloudflareServiceTokenList {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareServiceTokenList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareServiceTokenList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareServiceTokenSpec) DeepCopyInto(out *CloudflareServiceTokenSpec) {
+	*out = *in
+	if in.RecreateMissing != nil {
+		in, out := &in.RecreateMissing, &out.RecreateMissing
+		*out = new(bool)
+		**out = **in
+	}
+	in.Template.DeepCopyInto(&out.Template)
+	if in.Replication != nil {
+		in, out := &in.Replication, &out.Replication
+		*out = new(SecretReplicationSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretStore != nil {
+		in, out := &in.SecretStore, &out.SecretStore
+		*out = new(SecretStoreSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]ApplicationReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareServiceTokenSpec.
+func (in *CloudflareServiceTokenSpec) DeepCopy() *CloudflareServiceTokenSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareServiceTokenSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareServiceTokenStatus) DeepCopyInto(out *CloudflareServiceTokenStatus) {
+	*out = *in
+	in.CreatedAt.DeepCopyInto(&out.CreatedAt)
+	in.UpdatedAt.DeepCopyInto(&out.UpdatedAt)
+	in.ExpiresAt.DeepCopyInto(&out.ExpiresAt)
+	if in.LastSeenAt != nil {
+		in, out := &in.LastSeenAt, &out.LastSeenAt
+		*out = (*in).DeepCopy()
+	}
+	if in.OverlapEndsAt != nil {
+		in, out := &in.OverlapEndsAt, &out.OverlapEndsAt
+		*out = (*in).DeepCopy()
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(SecretRef)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = make([]SecretReplicaStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastRotatedAt != nil {
+		in, out := &in.LastRotatedAt, &out.LastRotatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRotationRequestedAt != nil {
+		in, out := &in.LastRotationRequestedAt, &out.LastRotationRequestedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Dependents != nil {
+		in, out := &in.Dependents, &out.Dependents
+		*out = make([]ResourceReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareServiceTokenStatus.
+func (in *CloudflareServiceTokenStatus) DeepCopy() *CloudflareServiceTokenStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareServiceTokenStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevicePosture) DeepCopyInto(out *DevicePosture) {
+	*out = *in
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(DevicePostureReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePosture.
+func (in *DevicePosture) DeepCopy() *DevicePosture {
+	if in == nil {
+		return nil
+	}
+	out := new(DevicePosture)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevicePostureReference) DeepCopyInto(out *DevicePostureReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePostureReference.
+func (in *DevicePostureReference) DeepCopy() *DevicePostureReference {
+	if in == nil {
+		return nil
+	}
+	out := new(DevicePostureReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevicePostureRuleInput) DeepCopyInto(out *DevicePostureRuleInput) {
+	*out = *in
+	if in.RequireAll != nil {
+		in, out := &in.RequireAll, &out.RequireAll
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CheckDisks != nil {
+		in, out := &in.CheckDisks, &out.CheckDisks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePostureRuleInput.
+func (in *DevicePostureRuleInput) DeepCopy() *DevicePostureRuleInput {
+	if in == nil {
+		return nil
+	}
+	out := new(DevicePostureRuleInput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevicePostureRuleMatch) DeepCopyInto(out *DevicePostureRuleMatch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevicePostureRuleMatch.
+func (in *DevicePostureRuleMatch) DeepCopy() *DevicePostureRuleMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(DevicePostureRuleMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalEvaluation) DeepCopyInto(out *ExternalEvaluation) {
+	*out = *in
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(ExternalEvaluationReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalEvaluation.
+func (in *ExternalEvaluation) DeepCopy() *ExternalEvaluation {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalEvaluation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalEvaluationReference) DeepCopyInto(out *ExternalEvaluationReference) {
+	*out = *in
+	out.Service = in.Service
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalEvaluationReference.
+func (in *ExternalEvaluationReference) DeepCopy() *ExternalEvaluationReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalEvaluationReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GoogleGroup) DeepCopyInto(out *GoogleGroup) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GoogleGroup.
+func (in *GoogleGroup) DeepCopy() *GoogleGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(GoogleGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyReference) DeepCopyInto(out *KeyReference) {
+	*out = *in
+	if in.Optional != nil {
+		in, out := &in.Optional, &out.Optional
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyReference.
+func (in *KeyReference) DeepCopy() *KeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *List) DeepCopyInto(out *List) {
+	*out = *in
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(ListReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new List.
+func (in *List) DeepCopy() *List {
+	if in == nil {
+		return nil
+	}
+	out := new(List)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListItemsSource) DeepCopyInto(out *ListItemsSource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListItemsSource.
+func (in *ListItemsSource) DeepCopy() *ListItemsSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ListItemsSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListReference) DeepCopyInto(out *ListReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListReference.
+func (in *ListReference) DeepCopy() *ListReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ListReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCClaim) DeepCopyInto(out *OIDCClaim) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClaim.
+func (in *OIDCClaim) DeepCopy() *OIDCClaim {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCClaim)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OktaGroup) DeepCopyInto(out *OktaGroup) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OktaGroup.
+func (in *OktaGroup) DeepCopy() *OktaGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(OktaGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantFrom) DeepCopyInto(out *ReferenceGrantFrom) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantFrom.
+func (in *ReferenceGrantFrom) DeepCopy() *ReferenceGrantFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantTo) DeepCopyInto(out *ReferenceGrantTo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantTo.
+func (in *ReferenceGrantTo) DeepCopy() *ReferenceGrantTo {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantTo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceReference) DeepCopyInto(out *ResourceReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceReference.
+func (in *ResourceReference) DeepCopy() *ResourceReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceSelector) DeepCopyInto(out *ResourceSelector) {
+	*out = *in
+	in.LabelSelector.DeepCopyInto(&out.LabelSelector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceSelector.
+func (in *ResourceSelector) DeepCopy() *ResourceSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleValuesSource) DeepCopyInto(out *RuleValuesSource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(KeyReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(KeyReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeExternalIPs != nil {
+		in, out := &in.NodeExternalIPs, &out.NodeExternalIPs
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ServiceLoadBalancer != nil {
+		in, out := &in.ServiceLoadBalancer, &out.ServiceLoadBalancer
+		*out = new(ServiceReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleValuesSource.
+func (in *RuleValuesSource) DeepCopy() *RuleValuesSource {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleValuesSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretRef) DeepCopyInto(out *SecretRef) {
+	*out = *in
+	out.LocalObjectReference = in.LocalObjectReference
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(VaultSecretRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretRef.
+func (in *SecretRef) DeepCopy() *SecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretReplicaStatus) DeepCopyInto(out *SecretReplicaStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretReplicaStatus.
+func (in *SecretReplicaStatus) DeepCopy() *SecretReplicaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretReplicaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretReplicationSpec) DeepCopyInto(out *SecretReplicationSpec) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretReplicationSpec.
+func (in *SecretReplicationSpec) DeepCopy() *SecretReplicationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretReplicationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretStoreSpec) DeepCopyInto(out *SecretStoreSpec) {
+	*out = *in
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(VaultStoreSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretStoreSpec.
+func (in *SecretStoreSpec) DeepCopy() *SecretStoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretStoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretTemplateSpec) DeepCopyInto(out *SecretTemplateSpec) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Data != nil {
+		in, out := &in.Data, &out.Data
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretTemplateSpec.
+func (in *SecretTemplateSpec) DeepCopy() *SecretTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceReference) DeepCopyInto(out *ServiceReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceReference.
+func (in *ServiceReference) DeepCopy() *ServiceReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceToken) DeepCopyInto(out *ServiceToken) {
+	*out = *in
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(ServiceTokenReference)
+		**out = **in
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(ResourceSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceToken.
+func (in *ServiceToken) DeepCopy() *ServiceToken {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceTokenBindingStatus) DeepCopyInto(out *ServiceTokenBindingStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceTokenBindingStatus.
+func (in *ServiceTokenBindingStatus) DeepCopy() *ServiceTokenBindingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceTokenBindingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceTokenBindingsSpec) DeepCopyInto(out *ServiceTokenBindingsSpec) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceTokenBindingsSpec.
+func (in *ServiceTokenBindingsSpec) DeepCopy() *ServiceTokenBindingsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceTokenBindingsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceTokenReference) DeepCopyInto(out *ServiceTokenReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceTokenReference.
+func (in *ServiceTokenReference) DeepCopy() *ServiceTokenReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceTokenReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultSecretRef) DeepCopyInto(out *VaultSecretRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultSecretRef.
+func (in *VaultSecretRef) DeepCopy() *VaultSecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultSecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultStoreSpec) DeepCopyInto(out *VaultStoreSpec) {
+	*out = *in
+	in.TokenSecretRef.DeepCopyInto(&out.TokenSecretRef)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultStoreSpec.
+func (in *VaultStoreSpec) DeepCopy() *VaultStoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultStoreSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -34,11 +34,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	cloudflarev1 "github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/config"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/controller"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/ctrlhelper"
+	cfwebhook "github.com/bojanzelic/cloudflare-zero-trust-operator/internal/webhook"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -159,9 +161,10 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&controller.CloudflareServiceTokenReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Helper: controllerHelper,
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Helper:   controllerHelper,
+		Recorder: mgr.GetEventRecorderFor("cloudflareservicetoken-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CloudflareServiceToken")
 		os.Exit(1)
@@ -174,6 +177,29 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "CloudflareAccessApplication")
 		os.Exit(1)
 	}
+	if err = (&controller.CloudflareDevicePostureRuleReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Helper: controllerHelper,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CloudflareDevicePostureRule")
+		os.Exit(1)
+	}
+	if err = (&controller.CloudflareListReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Helper: controllerHelper,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CloudflareList")
+		os.Exit(1)
+	}
+	// the webhook server needs a serving certificate, so the pod webhook is only served when enabled explicitly
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		mgr.GetWebhookServer().Register(cfwebhook.PodMutatePath, &webhook.Admission{Handler: &cfwebhook.PodCredentialsInjector{
+			Client:  mgr.GetClient(),
+			Decoder: admission.NewDecoder(mgr.GetScheme()),
+		}})
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
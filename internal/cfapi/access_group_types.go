@@ -112,6 +112,48 @@ func NewAccessGroupOktaGroup(name string, identityProviderID string) cloudflare.
 	}
 }
 
+func NewAccessGroupDevicePosture(id string) cloudflare.AccessGroupDevicePosture {
+	return cloudflare.AccessGroupDevicePosture{
+		DevicePosture: struct {
+			ID string `json:"integration_uid"`
+		}{
+			ID: id,
+		},
+	}
+}
+
+func NewAccessGroupEmailList(id string) cloudflare.AccessGroupEmailList {
+	return cloudflare.AccessGroupEmailList{
+		EmailList: struct {
+			ID string `json:"id"`
+		}{
+			ID: id,
+		},
+	}
+}
+
+func NewAccessGroupIPList(id string) cloudflare.AccessGroupIPList {
+	return cloudflare.AccessGroupIPList{
+		IPList: struct {
+			ID string `json:"id"`
+		}{
+			ID: id,
+		},
+	}
+}
+
+func NewAccessGroupExternalEvaluation(evaluateURL string, keysURL string) cloudflare.AccessGroupExternalEvaluation {
+	return cloudflare.AccessGroupExternalEvaluation{
+		ExternalEvaluation: struct {
+			EvaluateURL string `json:"evaluate_url"`
+			KeysURL     string `json:"keys_url"`
+		}{
+			EvaluateURL: evaluateURL,
+			KeysURL:     keysURL,
+		},
+	}
+}
+
 func NewAccessGroupOIDCClaim(name string, value string, identityProviderID string) AccessGroupOIDCClaim {
 	return AccessGroupOIDCClaim{
 		OIDC: struct {
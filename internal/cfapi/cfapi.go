@@ -2,6 +2,9 @@ package cfapi
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"time"
 
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfcollections"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cftypes"
@@ -272,11 +275,23 @@ func (a *API) DeleteAccessPolicy(ctx context.Context, appID string, policyID str
 	return errors.Wrap(err, "unable to update access Policy")
 }
 
+// ServiceTokens lists the service tokens of the account. The list is requested directly as the last seen timestamp
+// isn't part of cloudflare.AccessServiceToken.
 func (a *API) ServiceTokens(ctx context.Context) ([]cftypes.ExtendedServiceToken, error) {
-	account := cloudflare.AccountIdentifier(a.CFAccountID)
+	res, err := a.client.Raw(ctx, http.MethodGet, "/accounts/"+a.CFAccountID+"/access/service_tokens", nil, nil)
+	if err != nil {
+		return []cftypes.ExtendedServiceToken{}, errors.Wrap(err, "unable to get service tokens")
+	}
+
+	tokens := []struct {
+		cloudflare.AccessServiceToken
+		LastSeenAt *time.Time `json:"last_seen_at"`
+	}{}
+	if err := json.Unmarshal(res.Result, &tokens); err != nil {
+		return []cftypes.ExtendedServiceToken{}, errors.Wrap(err, "unable to decode service tokens")
+	}
 
 	extendedTokens := []cftypes.ExtendedServiceToken{}
-	tokens, _, err := a.client.ListAccessServiceTokens(ctx, account, cloudflare.ListAccessServiceTokensParams{})
 	for _, token := range tokens {
 		extendedTokens = append(extendedTokens, cftypes.ExtendedServiceToken{
 			AccessServiceToken: cloudflare.AccessServiceToken{
@@ -286,18 +301,21 @@ func (a *API) ServiceTokens(ctx context.Context) ([]cftypes.ExtendedServiceToken
 				ID:        token.ID,
 				Name:      token.Name,
 				ClientID:  token.ClientID,
+				Duration:  token.Duration,
 			},
+			LastSeenAt: token.LastSeenAt,
 		})
 	}
 
-	return extendedTokens, errors.Wrap(err, "unable to get service tokens")
+	return extendedTokens, nil
 }
 
 func (a *API) CreateAccessServiceToken(ctx context.Context, token cftypes.ExtendedServiceToken) (cftypes.ExtendedServiceToken, error) {
 	account := cloudflare.AccountIdentifier(a.CFAccountID)
 
 	params := cloudflare.CreateAccessServiceTokenParams{
-		Name: token.Name,
+		Name:     token.Name,
+		Duration: token.Duration,
 	}
 
 	res, err := a.client.CreateAccessServiceToken(ctx, account, params)
@@ -310,6 +328,7 @@ func (a *API) CreateAccessServiceToken(ctx context.Context, token cftypes.Extend
 			ID:        res.ID,
 			Name:      res.Name,
 			ClientID:  res.ClientID,
+			Duration:  res.Duration,
 		},
 	}
 
@@ -320,13 +339,24 @@ func (a *API) UpdateAccessServiceToken(ctx context.Context, token cftypes.Extend
 	account := cloudflare.AccountIdentifier(a.CFAccountID)
 
 	params := cloudflare.UpdateAccessServiceTokenParams{
-		Name: token.Name,
-		UUID: token.ID,
+		Name:     token.Name,
+		UUID:     token.ID,
+		Duration: token.Duration,
 	}
 
-	_, err := a.client.UpdateAccessServiceToken(ctx, account, params)
+	res, err := a.client.UpdateAccessServiceToken(ctx, account, params)
 
-	return token, errors.Wrap(err, "unable to update access Policy")
+	extendedToken := token
+	extendedToken.Name = res.Name
+	extendedToken.Duration = res.Duration
+	if res.ExpiresAt != nil {
+		extendedToken.ExpiresAt = res.ExpiresAt
+	}
+	if res.UpdatedAt != nil {
+		extendedToken.UpdatedAt = res.UpdatedAt
+	}
+
+	return extendedToken, errors.Wrap(err, "unable to update access service token")
 }
 
 func (a *API) RotateAccessServiceToken(ctx context.Context, token cftypes.ExtendedServiceToken) (cftypes.ExtendedServiceToken, error) {
@@ -342,12 +372,32 @@ func (a *API) RotateAccessServiceToken(ctx context.Context, token cftypes.Extend
 			ID:        res.ID,
 			Name:      res.Name,
 			ClientID:  res.ClientID,
+			Duration:  res.Duration,
 		},
 	}
 
 	return extendedToken, errors.Wrap(err, "unable to update access Policy")
 }
 
+func (a *API) RefreshAccessServiceToken(ctx context.Context, token cftypes.ExtendedServiceToken) (cftypes.ExtendedServiceToken, error) {
+	account := cloudflare.AccountIdentifier(a.CFAccountID)
+	res, err := a.client.RefreshAccessServiceToken(ctx, account, token.ID)
+
+	extendedToken := cftypes.ExtendedServiceToken{
+		AccessServiceToken: cloudflare.AccessServiceToken{
+			CreatedAt: res.CreatedAt,
+			UpdatedAt: res.UpdatedAt,
+			ExpiresAt: res.ExpiresAt,
+			ID:        res.ID,
+			Name:      res.Name,
+			ClientID:  res.ClientID,
+			Duration:  res.Duration,
+		},
+	}
+
+	return extendedToken, errors.Wrap(err, "unable to refresh access service token")
+}
+
 func (a *API) DeleteAccessServiceToken(ctx context.Context, tokenID string) error {
 	account := cloudflare.AccountIdentifier(a.CFAccountID)
 
@@ -355,3 +405,110 @@ func (a *API) DeleteAccessServiceToken(ctx context.Context, tokenID string) erro
 
 	return errors.Wrap(err, "unable to update access Policy")
 }
+
+func (a *API) DevicePostureRules(ctx context.Context) (cfcollections.DevicePostureRuleCollection, error) {
+	rules, _, err := a.client.DevicePostureRules(ctx, a.CFAccountID)
+
+	return cfcollections.DevicePostureRuleCollection(rules), errors.Wrap(err, "unable to get device posture rules")
+}
+
+func (a *API) DevicePostureRule(ctx context.Context, ruleID string) (cloudflare.DevicePostureRule, error) {
+	rule, err := a.client.DevicePostureRule(ctx, a.CFAccountID, ruleID)
+
+	return rule, errors.Wrap(err, "unable to get device posture rule")
+}
+
+func (a *API) CreateDevicePostureRule(ctx context.Context, rule cloudflare.DevicePostureRule) (cloudflare.DevicePostureRule, error) {
+	rule.ID = ""
+	res, err := a.client.CreateDevicePostureRule(ctx, a.CFAccountID, rule)
+
+	return res, errors.Wrap(err, "unable to create device posture rule")
+}
+
+func (a *API) UpdateDevicePostureRule(ctx context.Context, rule cloudflare.DevicePostureRule) (cloudflare.DevicePostureRule, error) {
+	res, err := a.client.UpdateDevicePostureRule(ctx, a.CFAccountID, rule)
+
+	return res, errors.Wrap(err, "unable to update device posture rule")
+}
+
+func (a *API) DeleteDevicePostureRule(ctx context.Context, ruleID string) error {
+	err := a.client.DeleteDevicePostureRule(ctx, a.CFAccountID, ruleID)
+
+	return errors.Wrap(err, "unable to delete device posture rule")
+}
+
+func (a *API) TeamsLists(ctx context.Context) (cfcollections.TeamsListCollection, error) {
+	account := cloudflare.AccountIdentifier(a.CFAccountID)
+
+	lists, _, err := a.client.ListTeamsLists(ctx, account, cloudflare.ListTeamListsParams{})
+
+	return cfcollections.TeamsListCollection(lists), errors.Wrap(err, "unable to get lists")
+}
+
+func (a *API) TeamsList(ctx context.Context, listID string) (cloudflare.TeamsList, error) {
+	account := cloudflare.AccountIdentifier(a.CFAccountID)
+
+	list, err := a.client.GetTeamsList(ctx, account, listID)
+
+	return list, errors.Wrap(err, "unable to get list")
+}
+
+func (a *API) TeamsListItems(ctx context.Context, listID string) ([]cloudflare.TeamsListItem, error) {
+	account := cloudflare.AccountIdentifier(a.CFAccountID)
+
+	items, _, err := a.client.ListTeamsListItems(ctx, account, cloudflare.ListTeamsListItemsParams{ListID: listID})
+
+	return items, errors.Wrap(err, "unable to get list items")
+}
+
+func (a *API) CreateTeamsList(ctx context.Context, list cloudflare.TeamsList) (cloudflare.TeamsList, error) {
+	account := cloudflare.AccountIdentifier(a.CFAccountID)
+
+	params := cloudflare.CreateTeamsListParams{
+		Name:        list.Name,
+		Type:        list.Type,
+		Description: list.Description,
+		Items:       list.Items,
+	}
+
+	res, err := a.client.CreateTeamsList(ctx, account, params)
+
+	return res, errors.Wrap(err, "unable to create list")
+}
+
+func (a *API) UpdateTeamsList(ctx context.Context, list cloudflare.TeamsList) (cloudflare.TeamsList, error) {
+	account := cloudflare.AccountIdentifier(a.CFAccountID)
+
+	params := cloudflare.UpdateTeamsListParams{
+		ID:          list.ID,
+		Name:        list.Name,
+		Type:        list.Type,
+		Description: list.Description,
+	}
+
+	res, err := a.client.UpdateTeamsList(ctx, account, params)
+
+	return res, errors.Wrap(err, "unable to update list")
+}
+
+func (a *API) PatchTeamsListItems(ctx context.Context, listID string, add []cloudflare.TeamsListItem, remove []string) (cloudflare.TeamsList, error) {
+	account := cloudflare.AccountIdentifier(a.CFAccountID)
+
+	params := cloudflare.PatchTeamsListParams{
+		ID:     listID,
+		Append: add,
+		Remove: remove,
+	}
+
+	res, err := a.client.PatchTeamsList(ctx, account, params)
+
+	return res, errors.Wrap(err, "unable to update list items")
+}
+
+func (a *API) DeleteTeamsList(ctx context.Context, listID string) error {
+	account := cloudflare.AccountIdentifier(a.CFAccountID)
+
+	err := a.client.DeleteTeamsList(ctx, account, listID)
+
+	return errors.Wrap(err, "unable to delete list")
+}
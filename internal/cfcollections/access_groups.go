@@ -1,9 +1,6 @@
 package cfcollections
 
 import (
-	"encoding/json"
-	"reflect"
-
 	cloudflare "github.com/cloudflare/cloudflare-go"
 )
 
@@ -26,22 +23,7 @@ func AccessGroupEqual(first cloudflare.AccessGroup, second cloudflare.AccessGrou
 		return false
 	}
 
-	v1, _ := json.Marshal(first.Include)  //nolint:errchkjson,varnamelen
-	v2, _ := json.Marshal(second.Include) //nolint:errchkjson,varnamelen
-
-	if !reflect.DeepEqual(v1, v2) {
-		return false
-	}
-
-	v1, _ = json.Marshal(first.Exclude)  //nolint:errchkjson
-	v2, _ = json.Marshal(second.Exclude) //nolint:errchkjson
-
-	if !reflect.DeepEqual(v1, v2) {
-		return false
-	}
-
-	v1, _ = json.Marshal(first.Require)  //nolint:errchkjson
-	v2, _ = json.Marshal(second.Require) //nolint:errchkjson
-
-	return reflect.DeepEqual(v1, v2)
+	return AccessRulesEqual(first.Include, second.Include) &&
+		AccessRulesEqual(first.Exclude, second.Exclude) &&
+		AccessRulesEqual(first.Require, second.Require)
 }
@@ -1,6 +1,7 @@
 package cfcollections_test
 
 import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfapi"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfcollections"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -100,6 +101,50 @@ var _ = Describe("AccessGroups", Label("AccessGroup"), func() {
 
 			Expect(cfcollections.AccessGroupEqual(first, second)).To(BeTrue())
 		})
+
+		It("should be able able to find equality with an external evaluation", func() {
+			first := cloudflare.AccessGroup{
+				Name: "test",
+				Include: []interface{}{
+					map[string]interface{}{
+						"external_evaluation": map[string]interface{}{
+							"evaluate_url": "https://authz.test.com/",
+							"keys_url":     "https://authz.test.com/keys",
+						},
+					},
+				},
+			}
+
+			second := cloudflare.AccessGroup{
+				Name:    "test",
+				Include: []interface{}{cfapi.NewAccessGroupExternalEvaluation("https://authz.test.com/", "https://authz.test.com/keys")},
+			}
+
+			Expect(cfcollections.AccessGroupEqual(first, second)).To(BeTrue())
+
+			second.Include = []interface{}{cfapi.NewAccessGroupExternalEvaluation("https://authz.test.com/v2", "https://authz.test.com/keys")}
+
+			Expect(cfcollections.AccessGroupEqual(first, second)).To(BeFalse())
+		})
+		It("should be able able to find equality regardless of the order", func() {
+			first := cloudflare.AccessGroup{
+				Name:    "test",
+				Include: []interface{}{cfapi.NewAccessGroupEmail("test@test.com"), cfapi.NewAccessGroupEmail("test2@test.com")},
+				Require: []interface{}{cfapi.NewAccessGroupCertificate()},
+			}
+
+			second := cloudflare.AccessGroup{
+				Name:    "test",
+				Include: []interface{}{cfapi.NewAccessGroupEmail("test2@test.com"), cfapi.NewAccessGroupEmail("test@test.com")},
+				Require: []interface{}{cfapi.NewAccessGroupCertificate()},
+			}
+
+			Expect(cfcollections.AccessGroupEqual(first, second)).To(BeTrue())
+
+			second.Require = nil
+
+			Expect(cfcollections.AccessGroupEqual(first, second)).To(BeFalse())
+		})
 	})
 	Context("AccessGroupCollection test", func() {
 		It("Should be able to find by name", func() {
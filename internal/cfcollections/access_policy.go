@@ -1,8 +1,6 @@
 package cfcollections
 
 import (
-	"encoding/json"
-	"reflect"
 	"sort"
 
 	cloudflare "github.com/cloudflare/cloudflare-go"
@@ -18,7 +16,6 @@ func (c AccessPolicyCollection) SortByPrecidence() {
 	})
 }
 
-//nolint:cyclop
 func AccessPoliciesEqual(first *cloudflare.AccessPolicy, second *cloudflare.AccessPolicy) bool {
 	if first == nil && second == nil {
 		return true
@@ -35,30 +32,7 @@ func AccessPoliciesEqual(first *cloudflare.AccessPolicy, second *cloudflare.Acce
 		return false
 	}
 
-	if len(first.Include) != 0 && len(second.Include) != 0 {
-		v1, _ := json.Marshal(first.Include)  //nolint:errchkjson
-		v2, _ := json.Marshal(second.Include) //nolint:errchkjson
-		if !reflect.DeepEqual(v1, v2) {
-			return false
-		}
-	}
-
-	if len(first.Exclude) != 0 && len(second.Exclude) != 0 {
-		v1, _ := json.Marshal(first.Exclude)  //nolint:errchkjson
-		v2, _ := json.Marshal(second.Exclude) //nolint:errchkjson
-
-		if !reflect.DeepEqual(v1, v2) {
-			return false
-		}
-	}
-
-	if len(first.Require) != 0 && len(second.Require) != 0 {
-		v1, _ := json.Marshal(first.Require)  //nolint:errchkjson
-		v2, _ := json.Marshal(second.Require) //nolint:errchkjson
-		if !reflect.DeepEqual(v1, v2) {
-			return false
-		}
-	}
-
-	return true
+	return AccessRulesEqual(first.Include, second.Include) &&
+		AccessRulesEqual(first.Exclude, second.Exclude) &&
+		AccessRulesEqual(first.Require, second.Require)
 }
@@ -36,6 +36,55 @@ var _ = Describe("AccessPolicy", Label("AccessPolicy"), func() {
 
 			Expect(cfcollections.AccessPoliciesEqual(&first, &second)).To(BeTrue())
 		})
+
+		It("should detect that all include rules have been removed", func() {
+			first := cloudflare.AccessPolicy{
+				Name:       "test",
+				Precedence: 1,
+				Include: []interface{}{
+					map[string]interface{}{
+						"email": map[string]interface{}{
+							"email": "test@test.com",
+						},
+					},
+				},
+			}
+
+			second := cloudflare.AccessPolicy{
+				Name:       "test",
+				Precedence: 1,
+				Include:    []interface{}{},
+			}
+
+			Expect(cfcollections.AccessPoliciesEqual(&first, &second)).To(BeFalse())
+			Expect(cfcollections.AccessPoliciesEqual(&second, &first)).To(BeFalse())
+		})
+
+		It("should ignore the order of the rules", func() {
+			first := cloudflare.AccessPolicy{
+				Name:       "test",
+				Precedence: 1,
+				Exclude: []interface{}{
+					map[string]interface{}{"geo": map[string]interface{}{"country_code": "US"}},
+					map[string]interface{}{"geo": map[string]interface{}{"country_code": "CA"}},
+				},
+			}
+
+			second := cloudflare.AccessPolicy{
+				Name:       "test",
+				Precedence: 1,
+				Exclude: []interface{}{
+					cloudflare.AccessGroupGeo{Geo: struct {
+						CountryCode string "json:\"country_code\""
+					}{CountryCode: "CA"}},
+					cloudflare.AccessGroupGeo{Geo: struct {
+						CountryCode string "json:\"country_code\""
+					}{CountryCode: "US"}},
+				},
+			}
+
+			Expect(cfcollections.AccessPoliciesEqual(&first, &second)).To(BeTrue())
+		})
 	})
 	Context("AccessPolicyCollection test", func() {
 		It("Should be able to sort by precidence", func() {
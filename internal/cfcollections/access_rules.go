@@ -0,0 +1,121 @@
+package cfcollections
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// AccessRulesEqual compares include/exclude/require rules semantically; the order and duplicates of
+// the rules are ignored and a nil list is equal to an empty one.
+func AccessRulesEqual(first []interface{}, second []interface{}) bool {
+	v1, ok := normalizeAccessRules(first)
+	if !ok {
+		return false
+	}
+
+	v2, ok := normalizeAccessRules(second)
+	if !ok {
+		return false
+	}
+
+	if len(v1) != len(v2) {
+		return false
+	}
+
+	for i := range v1 {
+		if v1[i] != v2[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeAccessRules returns the sorted set of rules in their canonical JSON form so that
+// the typed cloudflare structs and the generic maps returned by the API compare the same.
+func normalizeAccessRules(rules []interface{}) ([]string, bool) {
+	seen := map[string]struct{}{}
+	normalized := make([]string, 0, len(rules))
+
+	for _, rule := range rules {
+		raw, err := json.Marshal(rule)
+		if err != nil {
+			return nil, false
+		}
+
+		// round-trip through a generic value; maps are marshalled with sorted keys
+		var generic interface{}
+		if err := json.Unmarshal(raw, &generic); err != nil {
+			return nil, false
+		}
+
+		canonical, err := json.Marshal(generic)
+		if err != nil {
+			return nil, false
+		}
+
+		if _, ok := seen[string(canonical)]; ok {
+			continue
+		}
+
+		seen[string(canonical)] = struct{}{}
+		normalized = append(normalized, string(canonical))
+	}
+
+	sort.Strings(normalized)
+
+	return normalized, true
+}
+
+// ShardAccessRules splits rules into shards of at most size rules. Duplicates are removed and the rules are
+// sorted in their canonical form first, so that the same set of rules always results in the same shards.
+func ShardAccessRules(rules []interface{}, size int) ([][]interface{}, error) {
+	if size < 1 {
+		return nil, errors.Errorf("invalid shard size %d", size)
+	}
+
+	type canonicalRule struct {
+		canonical string
+		rule      interface{}
+	}
+
+	seen := map[string]struct{}{}
+	canonicalRules := make([]canonicalRule, 0, len(rules))
+
+	for _, rule := range rules {
+		normalized, ok := normalizeAccessRules([]interface{}{rule})
+		if !ok {
+			return nil, errors.Errorf("unable to normalize rule %v", rule)
+		}
+
+		if _, ok := seen[normalized[0]]; ok {
+			continue
+		}
+
+		seen[normalized[0]] = struct{}{}
+		canonicalRules = append(canonicalRules, canonicalRule{canonical: normalized[0], rule: rule})
+	}
+
+	sort.Slice(canonicalRules, func(i, j int) bool {
+		return canonicalRules[i].canonical < canonicalRules[j].canonical
+	})
+
+	shards := [][]interface{}{}
+	for start := 0; start < len(canonicalRules); start += size {
+		end := start + size
+		if end > len(canonicalRules) {
+			end = len(canonicalRules)
+		}
+
+		shard := make([]interface{}, 0, end-start)
+		for _, rule := range canonicalRules[start:end] {
+			shard = append(shard, rule.rule)
+		}
+
+		shards = append(shards, shard)
+	}
+
+	return shards, nil
+}
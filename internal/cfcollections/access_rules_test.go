@@ -0,0 +1,105 @@
+package cfcollections_test
+
+import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfapi"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfcollections"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AccessRules", Label("AccessRules"), func() {
+	Context("AccessRulesEqual test", func() {
+		It("should ignore the order of the rules", func() {
+			first := []interface{}{
+				cfapi.NewAccessGroupEmail("first@test.com"),
+				cfapi.NewAccessGroupEmail("second@test.com"),
+				cfapi.NewAccessGroupEveryone(),
+			}
+
+			second := []interface{}{
+				map[string]interface{}{"everyone": map[string]interface{}{}},
+				map[string]interface{}{"email": map[string]interface{}{"email": "second@test.com"}},
+				map[string]interface{}{"email": map[string]interface{}{"email": "first@test.com"}},
+			}
+
+			Expect(cfcollections.AccessRulesEqual(first, second)).To(BeTrue())
+		})
+
+		It("should ignore the order of the fields of a rule", func() {
+			first := []interface{}{cfapi.NewAccessGroupOktaGroup("admins", "idp")}
+			second := []interface{}{
+				map[string]interface{}{"okta": map[string]interface{}{"identity_provider_id": "idp", "name": "admins"}},
+			}
+
+			Expect(cfcollections.AccessRulesEqual(first, second)).To(BeTrue())
+		})
+
+		It("should ignore duplicated rules", func() {
+			first := []interface{}{cfapi.NewAccessGroupIP("10.0.0.0/8"), cfapi.NewAccessGroupIP("10.0.0.0/8")}
+			second := []interface{}{cfapi.NewAccessGroupIP("10.0.0.0/8")}
+
+			Expect(cfcollections.AccessRulesEqual(first, second)).To(BeTrue())
+		})
+
+		It("should treat nil and empty lists the same", func() {
+			Expect(cfcollections.AccessRulesEqual(nil, []interface{}{})).To(BeTrue())
+		})
+
+		It("should detect removed rules", func() {
+			first := []interface{}{cfapi.NewAccessGroupEmail("first@test.com")}
+
+			Expect(cfcollections.AccessRulesEqual(first, nil)).To(BeFalse())
+			Expect(cfcollections.AccessRulesEqual(nil, first)).To(BeFalse())
+		})
+
+		It("should detect a different rule type with the same value", func() {
+			first := []interface{}{cfapi.NewAccessGroupEmailList("id")}
+			second := []interface{}{cfapi.NewAccessGroupIPList("id")}
+
+			Expect(cfcollections.AccessRulesEqual(first, second)).To(BeFalse())
+		})
+	})
+
+	Context("ShardAccessRules test", func() {
+		It("should split rules into shards of the given size", func() {
+			rules := []interface{}{
+				cfapi.NewAccessGroupEmail("c@test.com"),
+				cfapi.NewAccessGroupEmail("a@test.com"),
+				cfapi.NewAccessGroupEmail("b@test.com"),
+				cfapi.NewAccessGroupEmail("a@test.com"),
+			}
+
+			shards, err := cfcollections.ShardAccessRules(rules, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(shards).To(Equal([][]interface{}{
+				{cfapi.NewAccessGroupEmail("a@test.com"), cfapi.NewAccessGroupEmail("b@test.com")},
+				{cfapi.NewAccessGroupEmail("c@test.com")},
+			}))
+		})
+
+		It("should return the same shards regardless of the order of the rules", func() {
+			first := []interface{}{cfapi.NewAccessGroupIP("10.0.0.0/8"), cfapi.NewAccessGroupEveryone(), cfapi.NewAccessGroupGeo("US")}
+			second := []interface{}{cfapi.NewAccessGroupGeo("US"), cfapi.NewAccessGroupIP("10.0.0.0/8"), cfapi.NewAccessGroupEveryone()}
+
+			firstShards, err := cfcollections.ShardAccessRules(first, 2)
+			Expect(err).ToNot(HaveOccurred())
+			secondShards, err := cfcollections.ShardAccessRules(second, 2)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(firstShards).To(Equal(secondShards))
+		})
+
+		It("should return no shards for no rules", func() {
+			shards, err := cfcollections.ShardAccessRules(nil, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(shards).To(BeEmpty())
+		})
+
+		It("should fail for sizes smaller than one", func() {
+			for _, size := range []int{0, -1} {
+				_, err := cfcollections.ShardAccessRules([]interface{}{cfapi.NewAccessGroupEveryone()}, size)
+				Expect(err).To(HaveOccurred(), "size %d", size)
+			}
+		})
+	})
+})
@@ -0,0 +1,37 @@
+package cfcollections
+
+import (
+	"reflect"
+	"strings"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+type DevicePostureRuleCollection []cloudflare.DevicePostureRule
+
+func (c DevicePostureRuleCollection) Len() int { return len(c) }
+
+func (c DevicePostureRuleCollection) GetByName(name string) *cloudflare.DevicePostureRule {
+	for _, rule := range c {
+		if rule.Name == name {
+			return &rule
+		}
+	}
+
+	return nil
+}
+
+func DevicePostureRuleEqual(first cloudflare.DevicePostureRule, second cloudflare.DevicePostureRule) bool {
+	if len(first.Match) != 0 || len(second.Match) != 0 {
+		if !reflect.DeepEqual(first.Match, second.Match) {
+			return false
+		}
+	}
+
+	return strings.TrimSpace(first.Name) == strings.TrimSpace(second.Name) &&
+		first.Type == second.Type &&
+		strings.TrimSpace(first.Description) == strings.TrimSpace(second.Description) &&
+		first.Schedule == second.Schedule &&
+		first.Expiration == second.Expiration &&
+		reflect.DeepEqual(first.Input, second.Input)
+}
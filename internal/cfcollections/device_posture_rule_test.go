@@ -0,0 +1,62 @@
+package cfcollections_test
+
+import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfcollections"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var _ = Describe("DevicePostureRule", Label("DevicePostureRule"), func() {
+	Context("DevicePostureRule test", func() {
+		It("Empty rules should be equal", func() {
+			first := cloudflare.DevicePostureRule{}
+			second := cloudflare.DevicePostureRule{Match: []cloudflare.DevicePostureRuleMatch{}}
+
+			Expect(cfcollections.DevicePostureRuleEqual(first, second)).To(BeTrue())
+		})
+
+		It("Differences in the input should not be equal", func() {
+			enabled := true
+			disabled := false
+			first := cloudflare.DevicePostureRule{
+				Name:  "firewall",
+				Type:  "firewall",
+				Input: cloudflare.DevicePostureRuleInput{Enabled: &enabled},
+			}
+			second := cloudflare.DevicePostureRule{
+				Name:  "firewall",
+				Type:  "firewall",
+				Input: cloudflare.DevicePostureRuleInput{Enabled: &disabled},
+			}
+
+			Expect(cfcollections.DevicePostureRuleEqual(first, second)).To(BeFalse())
+		})
+
+		It("Differences in the platform should not be equal", func() {
+			first := cloudflare.DevicePostureRule{
+				Name:  "os",
+				Match: []cloudflare.DevicePostureRuleMatch{{Platform: "windows"}},
+			}
+			second := cloudflare.DevicePostureRule{
+				Name:  "os",
+				Match: []cloudflare.DevicePostureRuleMatch{{Platform: "mac"}},
+			}
+
+			Expect(cfcollections.DevicePostureRuleEqual(first, second)).To(BeFalse())
+		})
+	})
+	Context("DevicePostureRuleCollection test", func() {
+		It("Should be able to find by name", func() {
+			rules := cfcollections.DevicePostureRuleCollection{
+				{Name: "first", Type: "firewall"},
+				{Name: "second", Type: "disk_encryption"},
+			}
+
+			Expect(rules.GetByName("first")).To(Equal(&rules[0]))
+			Expect(rules.GetByName("second")).To(Equal(&rules[1]))
+			Expect(rules.GetByName("third")).To(BeNil())
+		})
+	})
+})
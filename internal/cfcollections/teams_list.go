@@ -0,0 +1,58 @@
+package cfcollections
+
+import (
+	"strings"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+type TeamsListCollection []cloudflare.TeamsList
+
+func (c TeamsListCollection) Len() int { return len(c) }
+
+func (c TeamsListCollection) GetByName(name string) *cloudflare.TeamsList {
+	for _, list := range c {
+		if list.Name == name {
+			return &list
+		}
+	}
+
+	return nil
+}
+
+// TeamsListEqual compares the list metadata; items are compared with TeamsListItemsDiff.
+func TeamsListEqual(first cloudflare.TeamsList, second cloudflare.TeamsList) bool {
+	return strings.TrimSpace(first.Name) == strings.TrimSpace(second.Name) &&
+		first.Type == second.Type &&
+		strings.TrimSpace(first.Description) == strings.TrimSpace(second.Description)
+}
+
+// TeamsListItemsDiff returns the items that need to be appended to & the values that need to be removed from current to match expected.
+func TeamsListItemsDiff(current []cloudflare.TeamsListItem, expected []cloudflare.TeamsListItem) ([]cloudflare.TeamsListItem, []string) {
+	currentValues := make(map[string]struct{}, len(current))
+	for _, item := range current {
+		currentValues[item.Value] = struct{}{}
+	}
+
+	expectedValues := make(map[string]struct{}, len(expected))
+	add := []cloudflare.TeamsListItem{}
+	for _, item := range expected {
+		if _, ok := expectedValues[item.Value]; ok {
+			continue
+		}
+		expectedValues[item.Value] = struct{}{}
+
+		if _, ok := currentValues[item.Value]; !ok {
+			add = append(add, cloudflare.TeamsListItem{Value: item.Value, Description: item.Description})
+		}
+	}
+
+	remove := []string{}
+	for _, item := range current {
+		if _, ok := expectedValues[item.Value]; !ok {
+			remove = append(remove, item.Value)
+		}
+	}
+
+	return add, remove
+}
@@ -0,0 +1,58 @@
+package cfcollections_test
+
+import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfcollections"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var _ = Describe("TeamsList", Label("TeamsList"), func() {
+	Context("TeamsList test", func() {
+		It("Lists with different items but the same metadata should be equal", func() {
+			first := cloudflare.TeamsList{Name: "list", Type: "EMAIL", Items: []cloudflare.TeamsListItem{{Value: "a@test.com"}}}
+			second := cloudflare.TeamsList{Name: "list", Type: "EMAIL"}
+
+			Expect(cfcollections.TeamsListEqual(first, second)).To(BeTrue())
+		})
+
+		It("Differences in the type should not be equal", func() {
+			first := cloudflare.TeamsList{Name: "list", Type: "EMAIL"}
+			second := cloudflare.TeamsList{Name: "list", Type: "IP"}
+
+			Expect(cfcollections.TeamsListEqual(first, second)).To(BeFalse())
+		})
+
+		It("should find the items to add and remove", func() {
+			current := []cloudflare.TeamsListItem{{Value: "a@test.com"}, {Value: "b@test.com"}}
+			expected := []cloudflare.TeamsListItem{{Value: "b@test.com"}, {Value: "c@test.com"}, {Value: "c@test.com"}}
+
+			add, remove := cfcollections.TeamsListItemsDiff(current, expected)
+
+			Expect(add).To(Equal([]cloudflare.TeamsListItem{{Value: "c@test.com"}}))
+			Expect(remove).To(Equal([]string{"a@test.com"}))
+		})
+
+		It("should not find any changes for the same items in a different order", func() {
+			current := []cloudflare.TeamsListItem{{Value: "a@test.com"}, {Value: "b@test.com"}}
+			expected := []cloudflare.TeamsListItem{{Value: "b@test.com"}, {Value: "a@test.com"}}
+
+			add, remove := cfcollections.TeamsListItemsDiff(current, expected)
+
+			Expect(add).To(BeEmpty())
+			Expect(remove).To(BeEmpty())
+		})
+	})
+	Context("TeamsListCollection test", func() {
+		It("Should be able to find by name", func() {
+			lists := cfcollections.TeamsListCollection{
+				{Name: "first", Type: "EMAIL"},
+				{Name: "second", Type: "IP"},
+			}
+
+			Expect(lists.GetByName("first")).To(Equal(&lists[0]))
+			Expect(lists.GetByName("second")).To(Equal(&lists[1]))
+		})
+	})
+})
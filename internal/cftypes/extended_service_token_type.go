@@ -2,6 +2,7 @@ package cftypes
 
 import (
 	"errors"
+	"time"
 
 	"github.com/cloudflare/cloudflare-go"
 	corev1 "k8s.io/api/core/v1"
@@ -19,6 +20,7 @@ var (
 type ExtendedServiceToken struct {
 	cloudflare.AccessServiceToken
 	ClientSecret string
+	LastSeenAt   *time.Time
 	K8sSecretRef struct {
 		ClientIDKey     string
 		ClientSecretKey string
@@ -2,7 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
 	"github.com/spf13/viper"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -14,6 +17,9 @@ type ZeroTrustConfig struct {
 	AccountID string
 }
 
+// DefaultResyncInterval is the interval resources are reconciled again at unless CLOUDFLARE_RESYNC_INTERVAL is set.
+const DefaultResyncInterval = "1h"
+
 var (
 	ErrMissingCFFields  = errors.New("missing one of CLOUDFLARE_API_TOKEN or (CLOUDFLARE_API_EMAIL and CLOUDFLARE_API_KEY) needs to be set")
 	ErrMissingAccountID = errors.New("missing CLOUDFLARE_ACCOUNT_ID needs to be set")
@@ -24,6 +30,7 @@ func SetConfigDefaults() {
 	viper.SetDefault("cloudflare_api_key", "")
 	viper.SetDefault("cloudflare_api_token", "")
 	viper.SetDefault("cloudflare_account_id", "")
+	viper.SetDefault("cloudflare_resync_interval", DefaultResyncInterval)
 	viper.AutomaticEnv()
 }
 
@@ -57,3 +64,21 @@ func (c ZeroTrustConfig) IsValid() (bool, error) {
 
 	return true, nil
 }
+
+// ResyncInterval returns the interval a successfully reconciled resource is reconciled again at, to revert changes
+// made in cloudflare. The resync-interval annotation of the resource overrides CLOUDFLARE_RESYNC_INTERVAL; 0 disables it.
+func ResyncInterval(obj metav1.Object) (time.Duration, error) {
+	interval := viper.GetString("cloudflare_resync_interval")
+	source := "CLOUDFLARE_RESYNC_INTERVAL"
+	if val, ok := obj.GetAnnotations()[v1alpha1.AnnotationResyncInterval]; ok {
+		interval = val
+		source = v1alpha1.AnnotationResyncInterval
+	}
+
+	duration, err := time.ParseDuration(interval)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid %s %q", source, interval)
+	}
+
+	return duration, nil
+}
@@ -3,7 +3,9 @@ package config_test
 import (
 	"os"
 	"testing"
+	"time"
 
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/config"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -40,5 +42,27 @@ var _ = Describe("Config", Label("Config"), func() {
 			Expect(ztConfig.APIToken).To(Equal("2123457890"))
 			Expect(ztConfig.AccountID).To(Equal("3123457890"))
 		})
+
+		It("Should resolve the resync interval from the env and the annotation", func() {
+			config.SetConfigDefaults()
+			interval, err := config.ResyncInterval(&v1.ObjectMeta{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(interval).To(Equal(time.Hour))
+
+			Expect(os.Setenv("CLOUDFLARE_RESYNC_INTERVAL", "10m")).ToNot(HaveOccurred())
+			defer os.Unsetenv("CLOUDFLARE_RESYNC_INTERVAL")
+			interval, err = config.ResyncInterval(&v1.ObjectMeta{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(interval).To(Equal(10 * time.Minute))
+
+			interval, err = config.ResyncInterval(&v1.ObjectMeta{Annotations: map[string]string{v1alpha1.AnnotationResyncInterval: "0"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(interval).To(BeZero())
+
+			for _, invalid := range []string{"1 day", "-1m"} {
+				_, err = config.ResyncInterval(&v1.ObjectMeta{Annotations: map[string]string{v1alpha1.AnnotationResyncInterval: invalid}})
+				Expect(err).To(HaveOccurred())
+			}
+		})
 	})
 })
@@ -27,6 +27,7 @@ import (
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/services"
 	cloudflare "github.com/cloudflare/cloudflare-go"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,8 +36,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logger "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // CloudflareAccessApplicationReconciler reconciles a CloudflareAccessApplication object.
@@ -53,6 +56,10 @@ const (
 	statusDegrated = "Degraded"
 )
 
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps;nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflarereferencegrants,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessapplications,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessapplications/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessapplications/finalizers,verbs=update
@@ -69,6 +76,8 @@ func (r *CloudflareAccessApplicationReconciler) Reconcile(ctx context.Context, r
 
 	if err = r.Client.Get(ctx, req.NamespacedName, app); err != nil {
 		if k8serrors.IsNotFound(err) {
+			forgetSynced("CloudflareAccessApplication", req.NamespacedName)
+
 			return ctrl.Result{}, nil
 		}
 
@@ -111,8 +120,11 @@ func (r *CloudflareAccessApplicationReconciler) Reconcile(ctx context.Context, r
 	}
 
 	apService := &services.AccessPolicyService{
-		Client: r.Client,
-		Log:    log,
+		Client:    r.Client,
+		Log:       log,
+		Kind:      app.GetType(),
+		Namespace: app.Namespace,
+		Name:      app.Name,
 	}
 
 	if app.Status.AccessApplicationID == "" { // nolint
@@ -155,8 +167,10 @@ func (r *CloudflareAccessApplicationReconciler) Reconcile(ctx context.Context, r
 		}
 	}
 
+	appUpdated := false
 	if !cfcollections.AccessAppEqual(*existingaccessApp, app.ToCloudflare()) {
 		log.Info("app has changed - updating...", "name", app.Spec.Name, "domain", app.Spec.Domain)
+		appUpdated = true
 		accessapp, err := api.UpdateAccessApplication(ctx, app.ToCloudflare())
 		if err != nil {
 			return ctrl.Result{}, errors.Wrap(err, "unable to update access group")
@@ -190,23 +204,42 @@ func (r *CloudflareAccessApplicationReconciler) Reconcile(ctx context.Context, r
 		// don't requeue
 		return ctrl.Result{}, nil
 	}
-	expectedPolicies := app.Spec.Policies.ToCloudflare()
+
+	bindings, boundTokenIDs, err := services.ServiceTokenBindings(ctx, r.Client, app)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to resolve service token bindings")
+	}
+
+	policies := app.Spec.Policies
+	if bindingPolicy := app.ServiceTokenBindingPolicy(boundTokenIDs); bindingPolicy != nil {
+		policies = append(append(v1alpha1.CloudflareAccessPolicyList{}, policies...), *bindingPolicy)
+	}
+
+	expectedPolicies := policies.ToCloudflare()
 	expectedPolicies.SortByPrecidence()
 
-	err = r.ReconcilePolicies(ctx, api, app, currentPolicies, expectedPolicies)
+	// the policies are resolved after the app is updated; the drift of both is counted once
+	desired := desiredState(app.ToCloudflare(), expectedPolicies)
+
+	policiesUpdated, err := r.ReconcilePolicies(ctx, api, app, currentPolicies, expectedPolicies)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "unable get access policies")
 	}
 
+	if appUpdated || policiesUpdated {
+		recordDrift(app, desired)
+	}
+
 	if _, err = controllerutil.CreateOrPatch(ctx, r.Client, app, func() error {
 		meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{Type: statusAvailable, Status: metav1.ConditionTrue, Reason: "Reconciling", Message: "App Reconciled Successfully"})
+		app.Status.ServiceTokenBindings = bindings
 
 		return nil
 	}); err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareAccessApplication status")
 	}
 
-	return ctrl.Result{}, nil
+	return resyncResult(ctx, app, desired), nil
 }
 
 // nolint:dupl
@@ -238,9 +271,13 @@ func (r *CloudflareAccessApplicationReconciler) ReconcileStatus(ctx context.Cont
 	return nil
 }
 
+// ReconcilePolicies creates, updates and deletes the policies of the app in cloudflare to match the expected ones.
+// Returns true if any policy was changed.
+//
 //nolint:gocognit,cyclop
-func (r *CloudflareAccessApplicationReconciler) ReconcilePolicies(ctx context.Context, api *cfapi.API, app *v1alpha1.CloudflareAccessApplication, current, expected cfcollections.AccessPolicyCollection) error {
+func (r *CloudflareAccessApplicationReconciler) ReconcilePolicies(ctx context.Context, api *cfapi.API, app *v1alpha1.CloudflareAccessApplication, current, expected cfcollections.AccessPolicyCollection) (bool, error) {
 	log := logger.FromContext(ctx)
+	updated := false
 
 	for i := 0; i < len(current) || i < len(expected); i++ { //nolint:varnamelen
 		var k8sPolicy *cloudflare.AccessPolicy
@@ -255,6 +292,7 @@ func (r *CloudflareAccessApplicationReconciler) ReconcilePolicies(ctx context.Co
 		}
 
 		if !cfcollections.AccessPoliciesEqual(cfPolicy, k8sPolicy) {
+			updated = true
 			if cfPolicy == nil && k8sPolicy != nil {
 				action = "create"
 				log.Info("accesspolicy is missing - creating...", "policyName", k8sPolicy.Name, "domain", app.Spec.Domain)
@@ -273,18 +311,39 @@ func (r *CloudflareAccessApplicationReconciler) ReconcilePolicies(ctx context.Co
 			}
 
 			if err != nil {
-				return errors.Wrapf(err, "Unable to %s access policy", action)
+				return updated, errors.Wrapf(err, "Unable to %s access policy", action)
 			}
 		}
 	}
 
-	return nil
+	return updated, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *CloudflareAccessApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := setupReferenceIndexes(context.Background(), mgr, &v1alpha1.CloudflareAccessApplication{}, accessApplicationPolicies); err != nil {
+		return err
+	}
+
+	// the indexes are only available on the cached client of the manager
+	indexClient := mgr.GetClient()
+
 	//nolint:wrapcheck
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.CloudflareAccessApplication{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha1.CloudflareReferenceGrant{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			return requestsForReferenceGrant(ctx, r.Client, obj, "CloudflareAccessApplication", &v1alpha1.CloudflareAccessApplicationList{})
+		})).
+		Watches(&v1alpha1.CloudflareAccessGroup{}, dependentsHandler(indexClient, "CloudflareAccessGroup", requestsForDependentAccessApplications), builder.WithPredicates(referencedResourcePredicate())).
+		Watches(&v1alpha1.CloudflareServiceToken{}, dependentsHandler(indexClient, "CloudflareServiceToken", requestsForDependentAccessApplications), builder.WithPredicates(referencedResourcePredicate())).
+		Watches(&v1alpha1.CloudflareServiceToken{}, handler.EnqueueRequestsFromMapFunc(requestsForBoundAccessApplications), builder.WithPredicates(predicate.Or(referencedResourcePredicate(), serviceTokenBindingsPredicate()))).
+		Watches(&v1alpha1.CloudflareDevicePostureRule{}, dependentsHandler(indexClient, "CloudflareDevicePostureRule", requestsForDependentAccessApplications), builder.WithPredicates(referencedResourcePredicate())).
+		Watches(&v1alpha1.CloudflareList{}, dependentsHandler(indexClient, "CloudflareList", requestsForDependentAccessApplications), builder.WithPredicates(referencedResourcePredicate())).
+		Watches(&corev1.ConfigMap{}, dependentsHandler(indexClient, "ConfigMap", requestsForDependentAccessApplications)).
+		Watches(&corev1.Secret{}, dependentsHandler(indexClient, "Secret", requestsForDependentAccessApplications)).
+		Watches(&corev1.Service{}, dependentsHandler(indexClient, "Service", requestsForDependentAccessApplications), builder.WithPredicates(serviceLoadBalancerPredicate())).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			return requestsForNodeExternalIPs(ctx, indexClient, obj, &v1alpha1.CloudflareAccessApplicationList{}, accessApplicationPolicies)
+		}), builder.WithPredicates(nodeAddressesPredicate())).
 		Complete(r)
 }
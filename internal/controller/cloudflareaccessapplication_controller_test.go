@@ -503,5 +503,163 @@ var _ = Describe("CloudflareAccessApplication controller", Ordered, func() {
 				g.Expect(cfResource.Name).To(Equal(found.Spec.Name))
 			}, time.Second*45, time.Second).Should(Succeed(), logOutput.GetOutput()) //sometimes this is cached
 		})
+
+		It("should reconcile again once a referenced CloudflareAccessGroup is created", func() {
+			typeNamespaceName := types.NamespacedName{Name: "cloudflare-app-late-reference", Namespace: cloudflareName}
+
+			By("Creating the application before the referenced access group")
+			app := &v1alpha1.CloudflareAccessApplication{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessApplicationSpec{
+					Name:   "late reference policies",
+					Domain: "late-reference-policies.cf-operator-tests.uk",
+					Policies: v1alpha1.CloudflareAccessPolicyList{
+						{
+							Name:     "late_reference_test",
+							Decision: "allow",
+							Include: []v1alpha1.CloudFlareAccessGroupRule{{
+								AccessGroups: []v1alpha1.AccessGroup{
+									{
+										ValueFrom: &v1alpha1.AccessGroupReference{
+											Name:      typeNamespaceName.Name,
+											Namespace: typeNamespaceName.Namespace,
+										},
+									},
+								},
+							}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, app)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, app)).To(Not(HaveOccurred()))
+				g.Expect(app.Status.Conditions).ToNot(BeEmpty())
+				g.Expect(app.Status.Conditions[len(app.Status.Conditions)-1].Reason).To(Equal("InvalidReference"))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Creating the referenced access group")
+			group := &v1alpha1.CloudflareAccessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessGroupSpec{
+					Name: "late reference test",
+					Include: []v1alpha1.CloudFlareAccessGroupRule{
+						{
+							Emails: []string{"late-reference@cf-operator-tests.uk"},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, group)).To(Not(HaveOccurred()))
+				g.Expect(group.Status.AccessGroupID).ToNot(BeEmpty())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Checking the application picked up the access group ID without being edited")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, app)).To(Not(HaveOccurred()))
+				g.Expect(app.Status.AccessApplicationID).ToNot(BeEmpty())
+				policies, err := api.AccessPolicies(ctx, app.Status.AccessApplicationID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(policies).To(HaveLen(1))
+				g.Expect(policies[0].Include).To(ContainElement(HaveKeyWithValue("group", HaveKeyWithValue("id", group.Status.AccessGroupID))))
+			}, time.Second*30, time.Second).Should(Succeed())
+		})
+
+		It("should grant access to the service tokens binding the application", func() {
+			typeNamespaceName := types.NamespacedName{Name: "cloudflare-app-token-bindings", Namespace: cloudflareName}
+
+			By("Creating an application permitting service token bindings")
+			app := &v1alpha1.CloudflareAccessApplication{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessApplicationSpec{
+					Name:   "token bindings",
+					Domain: "token-bindings.cf-operator-tests.uk",
+					Policies: v1alpha1.CloudflareAccessPolicyList{
+						{
+							Name:     "token_bindings_test",
+							Decision: "allow",
+							Include: []v1alpha1.CloudFlareAccessGroupRule{{
+								Emails: []string{"token-bindings@cf-operator-tests.uk"},
+							}},
+						},
+					},
+					ServiceTokenBindings: &v1alpha1.ServiceTokenBindingsSpec{
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"access": "token-bindings"}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, app)).To(Not(HaveOccurred()))
+
+			By("Creating a permitted and a rejected service token")
+			bound := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name + "-bound",
+					Namespace: typeNamespaceName.Namespace,
+					Labels:    map[string]string{"access": "token-bindings"},
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name:         "integration token bindings bound",
+					Applications: []v1alpha1.ApplicationReference{{Name: typeNamespaceName.Name}},
+				},
+			}
+			rejected := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name + "-rejected",
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name:         "integration token bindings rejected",
+					Applications: []v1alpha1.ApplicationReference{{Name: typeNamespaceName.Name}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, bound)).To(Not(HaveOccurred()))
+			Expect(k8sClient.Create(ctx, rejected)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: bound.Name, Namespace: bound.Namespace}, bound)).To(Not(HaveOccurred()))
+				g.Expect(bound.Status.ServiceTokenID).ToNot(BeEmpty())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Checking the managed policy only includes the permitted token")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, app)).To(Not(HaveOccurred()))
+				g.Expect(app.Status.ServiceTokenBindings).To(ConsistOf(
+					v1alpha1.ServiceTokenBindingStatus{Namespace: bound.Namespace, Name: bound.Name, Bound: true},
+					HaveField("Bound", BeFalse()),
+				))
+				policies, err := api.AccessPolicies(ctx, app.Status.AccessApplicationID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(policies).To(HaveLen(2))
+				g.Expect(policies[1].Name).To(Equal("service token bindings"))
+				g.Expect(policies[1].Decision).To(Equal("non_identity"))
+				g.Expect(policies[1].Include).To(ConsistOf(HaveKeyWithValue("service_token", HaveKeyWithValue("token_id", bound.Status.ServiceTokenID))))
+			}, time.Second*30, time.Second).Should(Succeed())
+
+			By("Removing the binding")
+			bound.Spec.Applications = nil
+			Expect(k8sClient.Update(ctx, bound)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				policies, err := api.AccessPolicies(ctx, app.Status.AccessApplicationID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(policies).To(HaveLen(1))
+			}, time.Second*30, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, bound)).To(Not(HaveOccurred()))
+			Expect(k8sClient.Delete(ctx, rejected)).To(Not(HaveOccurred()))
+		})
 	})
 })
@@ -18,6 +18,7 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
 	v1alpha1 "github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfapi"
@@ -27,18 +28,25 @@ import (
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/services"
 	cloudflare "github.com/cloudflare/cloudflare-go"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logger "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// reasonReferenceCycle is the reason of the Degraded condition of access groups that reference each other in a cycle.
+const reasonReferenceCycle = "ReferenceCycle"
+
 // CloudflareAccessGroupReconciler reconciles a CloudflareAccessGroup object.
 type CloudflareAccessGroupReconciler struct {
 	client.Client
@@ -46,6 +54,11 @@ type CloudflareAccessGroupReconciler struct {
 	Helper *ctrlhelper.ControllerHelper
 }
 
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessapplications,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps;nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflarereferencegrants,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessgroups,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessgroups/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessgroups/finalizers,verbs=update
@@ -63,6 +76,8 @@ func (r *CloudflareAccessGroupReconciler) Reconcile(ctx context.Context, req ctr
 	err = r.Client.Get(ctx, req.NamespacedName, accessGroup)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
+			forgetSynced("CloudflareAccessGroup", req.NamespacedName)
+
 			return ctrl.Result{}, nil
 		}
 
@@ -108,6 +123,22 @@ func (r *CloudflareAccessGroupReconciler) Reconcile(ctx context.Context, req ctr
 		return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareAccessGroup status")
 	}
 
+	if err := r.Helper.ReconcileDependents(ctx, accessGroup); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to reconcile dependents")
+	}
+
+	cyclic, err := r.ReconcileCycles(ctx, accessGroup)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to detect reference cycles")
+	}
+
+	if cyclic {
+		log.Info("access group is part of a reference cycle")
+
+		// don't requeue; breaking the cycle changes a group in it which reconciles every group involved
+		return ctrl.Result{}, nil
+	}
+
 	cfAccessGroups, err := api.AccessGroups(ctx)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "unable to get access groups")
@@ -133,8 +164,11 @@ func (r *CloudflareAccessGroupReconciler) Reconcile(ctx context.Context, req ctr
 	}
 
 	apService := &services.AccessPolicyService{
-		Client: r.Client,
-		Log:    log,
+		Client:    r.Client,
+		Log:       log,
+		Kind:      accessGroup.GetType(),
+		Namespace: accessGroup.Namespace,
+		Name:      accessGroup.Name,
 	}
 
 	if err := apService.PopulateAccessPolicyReferences(ctx, []services.AccessPolicyList{accessGroup.Spec}); err != nil {
@@ -154,9 +188,14 @@ func (r *CloudflareAccessGroupReconciler) Reconcile(ctx context.Context, req ctr
 		return ctrl.Result{}, nil
 	}
 
+	newCfAG, err = r.ReconcileShards(ctx, api, cfAccessGroups, accessGroup)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to reconcile access group shards")
+	}
+
 	if existingCfAG == nil {
 		//nolint:varnamelen
-		ag, err := api.CreateAccessGroup(ctx, accessGroup.ToCloudflare())
+		ag, err := api.CreateAccessGroup(ctx, newCfAG)
 		if err != nil {
 			return ctrl.Result{}, errors.Wrap(err, "unable to create access group")
 		}
@@ -167,13 +206,21 @@ func (r *CloudflareAccessGroupReconciler) Reconcile(ctx context.Context, req ctr
 		existingCfAG = &ag
 	}
 
-	if !cfcollections.AccessGroupEqual(*existingCfAG, accessGroup.ToCloudflare()) {
+	desired := desiredState(newCfAG)
+	if !cfcollections.AccessGroupEqual(*existingCfAG, newCfAG) {
 		log.Info(newCfAG.Name + " has changed, updating...")
 
-		_, err := api.UpdateAccessGroup(ctx, accessGroup.ToCloudflare())
+		newCfAG.ID = accessGroup.Status.AccessGroupID
+		_, err := api.UpdateAccessGroup(ctx, newCfAG)
 		if err != nil {
 			return ctrl.Result{}, errors.Wrap(err, "unable to update access groups")
 		}
+		recordDrift(accessGroup, desired)
+	}
+
+	// shards are only removed once the parent group doesn't include them anymore
+	if err := r.DeleteSurplusShards(ctx, api, accessGroup); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to delete access group shards")
 	}
 
 	_, err = controllerutil.CreateOrPatch(ctx, r.Client, accessGroup, func() error {
@@ -188,7 +235,7 @@ func (r *CloudflareAccessGroupReconciler) Reconcile(ctx context.Context, req ctr
 
 	log.Info("reconciled successfully")
 
-	return ctrl.Result{}, nil
+	return resyncResult(ctx, accessGroup, desired), nil
 }
 
 // nolint:dupl
@@ -222,10 +269,236 @@ func (r *CloudflareAccessGroupReconciler) ReconcileStatus(ctx context.Context, c
 	return nil
 }
 
+// ReconcileShards returns the access group to send to cloudflare. When the include rules exceed maxRulesPerGroup they are
+// moved into managed child groups which are included by the returned group instead. Child groups no longer needed are
+// kept in the status until DeleteSurplusShards removes them.
+func (r *CloudflareAccessGroupReconciler) ReconcileShards(ctx context.Context, api *cfapi.API, cfAccessGroups cfcollections.AccessGroupCollection, accessGroup *v1alpha1.CloudflareAccessGroup) (cloudflare.AccessGroup, error) {
+	cfGroup := accessGroup.ToCloudflare()
+	limit := accessGroup.Spec.GetMaxRulesPerGroup()
+
+	shardRules := [][]interface{}{}
+	if len(cfGroup.Include) > limit {
+		var err error
+		if shardRules, err = cfcollections.ShardAccessRules(cfGroup.Include, limit); err != nil {
+			return cfGroup, errors.Wrap(err, "unable to shard include rules")
+		}
+	}
+
+	if len(shardRules) > limit {
+		return cfGroup, errors.Errorf("%d include rules exceed the limit of %d shards of %d rules", len(cfGroup.Include), limit, limit)
+	}
+
+	shards := make([]v1alpha1.AccessGroupShard, 0, len(shardRules))
+	include := make([]interface{}, 0, len(shardRules))
+
+	for i, rules := range shardRules {
+		shard := v1alpha1.AccessGroupShard{Name: fmt.Sprintf("%s (shard %d)", cfGroup.Name, i+1), RuleCount: len(rules)}
+		if i < len(accessGroup.Status.Shards) && accessGroup.Status.Shards[i].Name == shard.Name {
+			shard.AccessGroupID = accessGroup.Status.Shards[i].AccessGroupID
+		}
+
+		id, err := r.reconcileShard(ctx, api, cfAccessGroups, shard, rules)
+		if err != nil {
+			return cfGroup, err
+		}
+
+		shard.AccessGroupID = id
+		shards = append(shards, shard)
+		include = append(include, cfapi.NewAccessGroupAccessGroup(id))
+	}
+
+	// keep the previous shards which aren't reused until they are deleted
+	for _, previous := range accessGroup.Status.Shards {
+		reused := false
+		for _, shard := range shards {
+			reused = reused || shard.AccessGroupID == previous.AccessGroupID
+		}
+
+		if !reused {
+			shards = append(shards, v1alpha1.AccessGroupShard{Name: previous.Name, AccessGroupID: previous.AccessGroupID})
+		}
+	}
+
+	if err := r.setShards(ctx, accessGroup, shards); err != nil {
+		return cfGroup, err
+	}
+
+	if len(shardRules) > 0 {
+		cfGroup.Include = include
+	}
+
+	return cfGroup, nil
+}
+
+// reconcileShard creates, imports or updates the child group holding the given rules and returns its ID.
+func (r *CloudflareAccessGroupReconciler) reconcileShard(ctx context.Context, api *cfapi.API, cfAccessGroups cfcollections.AccessGroupCollection, shard v1alpha1.AccessGroupShard, rules []interface{}) (string, error) {
+	log := logger.FromContext(ctx)
+
+	newCfShard := cloudflare.AccessGroup{
+		ID:      shard.AccessGroupID,
+		Name:    shard.Name,
+		Include: rules,
+		Exclude: make([]interface{}, 0),
+		Require: make([]interface{}, 0),
+	}
+
+	var existing *cloudflare.AccessGroup
+	if shard.AccessGroupID != "" {
+		cfShard, err := api.AccessGroup(ctx, shard.AccessGroupID)
+		var notFound *cloudflare.NotFoundError
+		if errors.As(err, &notFound) {
+			log.Info("access group shard not found - recreating...", "accessGroupID", shard.AccessGroupID)
+		} else if err != nil {
+			return "", errors.Wrap(err, "unable to get access group shard")
+		} else {
+			existing = &cfShard
+		}
+	} else {
+		existing = cfAccessGroups.GetByName(shard.Name)
+	}
+
+	if existing == nil {
+		created, err := api.CreateAccessGroup(ctx, newCfShard)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to create access group shard")
+		}
+
+		return created.ID, nil
+	}
+
+	if !cfcollections.AccessGroupEqual(*existing, newCfShard) {
+		log.Info(shard.Name + " has changed, updating...")
+
+		newCfShard.ID = existing.ID
+		if _, err := api.UpdateAccessGroup(ctx, newCfShard); err != nil {
+			return "", errors.Wrap(err, "unable to update access group shard")
+		}
+	}
+
+	return existing.ID, nil
+}
+
+// DeleteSurplusShards deletes the child groups of the status which don't hold any rules anymore.
+func (r *CloudflareAccessGroupReconciler) DeleteSurplusShards(ctx context.Context, api *cfapi.API, accessGroup *v1alpha1.CloudflareAccessGroup) error {
+	shards := make([]v1alpha1.AccessGroupShard, 0, len(accessGroup.Status.Shards))
+
+	for _, shard := range accessGroup.Status.Shards {
+		if shard.RuleCount > 0 {
+			shards = append(shards, shard)
+
+			continue
+		}
+
+		if err := api.DeleteAccessGroup(ctx, shard.AccessGroupID); err != nil {
+			var notFound *cloudflare.NotFoundError
+			if !errors.As(err, &notFound) {
+				return errors.Wrap(err, "unable to delete access group shard")
+			}
+		}
+	}
+
+	if len(shards) == len(accessGroup.Status.Shards) {
+		return nil
+	}
+
+	return r.setShards(ctx, accessGroup, shards)
+}
+
+func (r *CloudflareAccessGroupReconciler) setShards(ctx context.Context, accessGroup *v1alpha1.CloudflareAccessGroup, shards []v1alpha1.AccessGroupShard) error {
+	group := accessGroup.DeepCopy()
+
+	_, err := controllerutil.CreateOrPatch(ctx, r.Client, group, func() error {
+		group.Status.Shards = shards
+
+		return nil
+	})
+
+	// CreateOrPatch re-fetches the object from k8s which removes the populated references of the spec
+	accessGroup.Status = group.Status
+
+	if err != nil {
+		return errors.Wrap(err, "Failed to update CloudflareAccessGroup status")
+	}
+
+	return nil
+}
+
+// ReconcileCycles detects reference cycles going through the access group before anything is sent to cloudflare.
+// The cycle is reported on every group involved; a condition reporting a cycle that no longer exists is removed.
+func (r *CloudflareAccessGroupReconciler) ReconcileCycles(ctx context.Context, accessGroup *v1alpha1.CloudflareAccessGroup) (bool, error) {
+	groups := &v1alpha1.CloudflareAccessGroupList{}
+	if err := r.Client.List(ctx, groups); err != nil {
+		return false, errors.Wrap(err, "unable to list CloudflareAccessGroups")
+	}
+
+	cycle := services.NewAccessGroupGraph(groups.Items).FindCycle(types.NamespacedName{Namespace: accessGroup.Namespace, Name: accessGroup.Name})
+
+	if cycle == nil {
+		if condition := meta.FindStatusCondition(accessGroup.Status.Conditions, statusDegrated); condition == nil || condition.Reason != reasonReferenceCycle {
+			return false, nil
+		}
+
+		_, err := controllerutil.CreateOrPatch(ctx, r.Client, accessGroup, func() error {
+			meta.RemoveStatusCondition(&accessGroup.Status.Conditions, statusDegrated)
+
+			return nil
+		})
+
+		return false, errors.Wrap(err, "Failed to update CloudflareAccessGroup status")
+	}
+
+	message := "reference cycle detected: " + services.FormatCycle(cycle)
+
+	// the last element closes the cycle and is the group itself
+	for _, ref := range cycle[:len(cycle)-1] {
+		group := &v1alpha1.CloudflareAccessGroup{}
+		if ref == (types.NamespacedName{Namespace: accessGroup.Namespace, Name: accessGroup.Name}) {
+			group = accessGroup
+		} else if err := r.Client.Get(ctx, ref, group); err != nil {
+			return true, errors.Wrapf(err, "unable to get CloudflareAccessGroup %s", ref)
+		}
+
+		if _, err := controllerutil.CreateOrPatch(ctx, r.Client, group, func() error {
+			meta.SetStatusCondition(&group.Status.Conditions, metav1.Condition{Type: statusDegrated, Status: metav1.ConditionFalse, Reason: reasonReferenceCycle, Message: message})
+
+			return nil
+		}); err != nil {
+			return true, errors.Wrap(err, "Failed to update CloudflareAccessGroup status")
+		}
+	}
+
+	return true, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *CloudflareAccessGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := setupReferenceIndexes(context.Background(), mgr, &v1alpha1.CloudflareAccessGroup{}, accessGroupPolicies); err != nil {
+		return err
+	}
+
+	// the indexes are only available on the cached client of the manager
+	indexClient := mgr.GetClient()
+
 	//nolint:wrapcheck
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.CloudflareAccessGroup{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha1.CloudflareReferenceGrant{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			return requestsForReferenceGrant(ctx, r.Client, obj, "CloudflareAccessGroup", &v1alpha1.CloudflareAccessGroupList{})
+		})).
+		Watches(&v1alpha1.CloudflareAccessGroup{}, dependentsHandler(indexClient, "CloudflareAccessGroup", requestsForDependentAccessGroups), builder.WithPredicates(referencedResourcePredicate())).
+		Watches(&v1alpha1.CloudflareAccessGroup{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			return requestsForCyclicAccessGroups(ctx, r.Client)
+		}), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha1.CloudflareServiceToken{}, dependentsHandler(indexClient, "CloudflareServiceToken", requestsForDependentAccessGroups), builder.WithPredicates(referencedResourcePredicate())).
+		Watches(&v1alpha1.CloudflareDevicePostureRule{}, dependentsHandler(indexClient, "CloudflareDevicePostureRule", requestsForDependentAccessGroups), builder.WithPredicates(referencedResourcePredicate())).
+		Watches(&v1alpha1.CloudflareList{}, dependentsHandler(indexClient, "CloudflareList", requestsForDependentAccessGroups), builder.WithPredicates(referencedResourcePredicate())).
+		Watches(&corev1.ConfigMap{}, dependentsHandler(indexClient, "ConfigMap", requestsForDependentAccessGroups)).
+		Watches(&corev1.Secret{}, dependentsHandler(indexClient, "Secret", requestsForDependentAccessGroups)).
+		Watches(&corev1.Service{}, dependentsHandler(indexClient, "Service", requestsForDependentAccessGroups), builder.WithPredicates(serviceLoadBalancerPredicate())).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			return requestsForNodeExternalIPs(ctx, indexClient, obj, &v1alpha1.CloudflareAccessGroupList{}, accessGroupPolicies)
+		}), builder.WithPredicates(nodeAddressesPredicate())).
+		Watches(&v1alpha1.CloudflareAccessGroup{}, referrersHandler(r.Client, "CloudflareAccessGroup", func() client.ObjectList { return &v1alpha1.CloudflareAccessGroupList{} }), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha1.CloudflareAccessApplication{}, referrersHandler(r.Client, "CloudflareAccessGroup", func() client.ObjectList { return &v1alpha1.CloudflareAccessGroupList{} }), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
@@ -12,6 +12,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -207,5 +208,426 @@ var _ = Describe("CloudflareAccessGroup controller", Ordered, func() {
 				g.Expect(group.Status.Conditions[0].Status).To(Equal(metav1.ConditionTrue))
 			}, time.Second*10, time.Second).Should(Succeed())
 		})
+
+		It("should only allow cross-namespace references permitted by a CloudflareReferenceGrant", func() {
+			const otherNamespace = "test-cloudflare-shared"
+
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: otherNamespace}})).To(Not(HaveOccurred()))
+
+			tokenNamespaceName := types.NamespacedName{Name: "shared-token", Namespace: otherNamespace}
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      tokenNamespaceName.Name,
+					Namespace: tokenNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name: "shared reference test token",
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, token)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, tokenNamespaceName, token)).To(Not(HaveOccurred()))
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			groupNamespaceName := types.NamespacedName{Name: "cross-namespace-reference", Namespace: namespace.Name}
+			group := &v1alpha1.CloudflareAccessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      groupNamespaceName.Name,
+					Namespace: groupNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessGroupSpec{
+					Name: "cross namespace reference test group",
+					Include: []v1alpha1.CloudFlareAccessGroupRule{
+						{
+							ServiceToken: []v1alpha1.ServiceToken{
+								{
+									ValueFrom: &v1alpha1.ServiceTokenReference{
+										Name:      token.Name,
+										Namespace: token.Namespace,
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+			By("Checking the reference is rejected")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, groupNamespaceName, group)).To(Not(HaveOccurred()))
+				condition := meta.FindStatusCondition(group.Status.Conditions, "Degraded")
+				g.Expect(condition).ToNot(BeNil())
+				g.Expect(condition.Reason).To(Equal("InvalidReference"))
+				g.Expect(condition.Message).To(ContainSubstring("CloudflareReferenceGrant"))
+				g.Expect(group.Status.AccessGroupID).To(BeEmpty())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Granting the reference")
+			grant := &v1alpha1.CloudflareReferenceGrant{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "allow-test-cloudflare",
+					Namespace: otherNamespace,
+				},
+				Spec: v1alpha1.CloudflareReferenceGrantSpec{
+					From: []v1alpha1.ReferenceGrantFrom{{Kind: "CloudflareAccessGroup", Namespace: namespace.Name}},
+					To:   []v1alpha1.ReferenceGrantTo{{Kind: "CloudflareServiceToken", Name: token.Name}},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, grant)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, groupNamespaceName, group)).To(Not(HaveOccurred()))
+				g.Expect(group.Status.AccessGroupID).ToNot(BeEmpty())
+				g.Expect(meta.IsStatusConditionTrue(group.Status.Conditions, "Available")).To(BeTrue())
+			}, time.Second*20, time.Second).Should(Succeed())
+		})
+
+		It("should reconcile service tokens selected by labels", func() {
+			newToken := func(name string) *v1alpha1.CloudflareServiceToken {
+				token := &v1alpha1.CloudflareServiceToken{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace.Name,
+						Labels:    map[string]string{"team": "payments"},
+					},
+					Spec: v1alpha1.CloudflareServiceTokenSpec{
+						Name: "selector test " + name,
+					},
+				}
+				Expect(k8sClient.Create(ctx, token)).To(Not(HaveOccurred()))
+
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace.Name}, token)).To(Not(HaveOccurred()))
+					g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				}, time.Second*10, time.Second).Should(Succeed())
+
+				return token
+			}
+
+			first := newToken("payments-first")
+
+			groupNamespaceName := types.NamespacedName{Name: "selected-tokens", Namespace: namespace.Name}
+			group := &v1alpha1.CloudflareAccessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      groupNamespaceName.Name,
+					Namespace: groupNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessGroupSpec{
+					Name: "selector test group",
+					Include: []v1alpha1.CloudFlareAccessGroupRule{
+						{
+							ServiceToken: []v1alpha1.ServiceToken{
+								{
+									Selector: &v1alpha1.ResourceSelector{
+										LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, groupNamespaceName, group)).To(Not(HaveOccurred()))
+				g.Expect(group.Status.AccessGroupID).ToNot(BeEmpty())
+				cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(cfGroup.Include).To(HaveLen(1))
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			By("Creating another matching token")
+			second := newToken("payments-second")
+
+			Eventually(func(g Gomega) {
+				cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(cfGroup.Include).To(HaveLen(2))
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			By("Removing a matching token")
+			Expect(k8sClient.Delete(ctx, first)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(cfGroup.Include).To(HaveLen(1))
+				g.Expect(cfGroup.Include[0]).To(HaveKeyWithValue("service_token", HaveKeyWithValue("token_id", second.Status.ServiceTokenID)))
+			}, time.Second*20, time.Second).Should(Succeed())
+		})
+
+		It("should skip selected service tokens in other namespaces which aren't permitted", func() {
+			const otherNamespace = "test-cloudflare-selected"
+
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: otherNamespace}})).To(Not(HaveOccurred()))
+
+			newToken := func(name string) *v1alpha1.CloudflareServiceToken {
+				token := &v1alpha1.CloudflareServiceToken{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: otherNamespace,
+						Labels:    map[string]string{"team": "billing"},
+					},
+					Spec: v1alpha1.CloudflareServiceTokenSpec{
+						Name: "unpermitted selector test " + name,
+					},
+				}
+				Expect(k8sClient.Create(ctx, token)).To(Not(HaveOccurred()))
+
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: otherNamespace}, token)).To(Not(HaveOccurred()))
+					g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				}, time.Second*10, time.Second).Should(Succeed())
+
+				return token
+			}
+
+			permitted := newToken("billing-permitted")
+			newToken("billing-unpermitted")
+
+			grant := &v1alpha1.CloudflareReferenceGrant{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "allow-billing-permitted",
+					Namespace: otherNamespace,
+				},
+				Spec: v1alpha1.CloudflareReferenceGrantSpec{
+					From: []v1alpha1.ReferenceGrantFrom{{Kind: "CloudflareAccessGroup", Namespace: namespace.Name}},
+					To:   []v1alpha1.ReferenceGrantTo{{Kind: "CloudflareServiceToken", Name: permitted.Name}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, grant)).To(Not(HaveOccurred()))
+
+			groupNamespaceName := types.NamespacedName{Name: "selected-unpermitted-tokens", Namespace: namespace.Name}
+			group := &v1alpha1.CloudflareAccessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      groupNamespaceName.Name,
+					Namespace: groupNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessGroupSpec{
+					Name: "unpermitted selector test group",
+					Include: []v1alpha1.CloudFlareAccessGroupRule{
+						{
+							ServiceToken: []v1alpha1.ServiceToken{
+								{
+									Selector: &v1alpha1.ResourceSelector{
+										Namespace:     otherNamespace,
+										LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "billing"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, groupNamespaceName, group)).To(Not(HaveOccurred()))
+				g.Expect(meta.IsStatusConditionTrue(group.Status.Conditions, "Available")).To(BeTrue())
+				cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(cfGroup.Include).To(HaveLen(1))
+				g.Expect(cfGroup.Include[0]).To(HaveKeyWithValue("service_token", HaveKeyWithValue("token_id", permitted.Status.ServiceTokenID)))
+			}, time.Second*20, time.Second).Should(Succeed())
+		})
+
+		It("should report reference cycles between access groups", func() {
+			newGroup := func(name string, ref string) *v1alpha1.CloudflareAccessGroup {
+				group := &v1alpha1.CloudflareAccessGroup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace.Name,
+					},
+					Spec: v1alpha1.CloudflareAccessGroupSpec{
+						Name: "cycle test " + name,
+						Include: []v1alpha1.CloudFlareAccessGroupRule{{
+							AccessGroups: []v1alpha1.AccessGroup{{
+								ValueFrom: &v1alpha1.AccessGroupReference{Namespace: namespace.Name, Name: ref},
+							}},
+						}},
+					},
+				}
+				Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+				return group
+			}
+
+			first := newGroup("cycle-a", "cycle-b")
+			second := newGroup("cycle-b", "cycle-a")
+
+			By("Expecting the cycle to be reported on both groups")
+			for _, group := range []*v1alpha1.CloudflareAccessGroup{first, second} {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: group.Name, Namespace: namespace.Name}, group)).To(Not(HaveOccurred()))
+					condition := meta.FindStatusCondition(group.Status.Conditions, "Degraded")
+					g.Expect(condition).ToNot(BeNil())
+					g.Expect(condition.Reason).To(Equal("ReferenceCycle"))
+					g.Expect(condition.Message).To(ContainSubstring(namespace.Name + "/cycle-a -> " + namespace.Name + "/cycle-b"))
+					g.Expect(group.Status.AccessGroupID).To(BeEmpty())
+				}, time.Second*10, time.Second).Should(Succeed())
+			}
+
+			By("Breaking the cycle")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: second.Name, Namespace: namespace.Name}, second)).To(Not(HaveOccurred()))
+			second.Spec.Include = []v1alpha1.CloudFlareAccessGroupRule{{Emails: []string{"cycle@domain.com"}}}
+			Expect(k8sClient.Update(ctx, second)).To(Not(HaveOccurred()))
+
+			for _, group := range []*v1alpha1.CloudflareAccessGroup{first, second} {
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: group.Name, Namespace: namespace.Name}, group)).To(Not(HaveOccurred()))
+					g.Expect(meta.FindStatusCondition(group.Status.Conditions, "Degraded")).To(BeNil())
+					g.Expect(group.Status.AccessGroupID).ToNot(BeEmpty())
+				}, time.Second*20, time.Second).Should(Succeed())
+			}
+		})
+
+		It("should not select an access group with its own selector", func() {
+			newGroup := func(name string, include v1alpha1.CloudFlareAccessGroupRule) *v1alpha1.CloudflareAccessGroup {
+				group := &v1alpha1.CloudflareAccessGroup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace.Name,
+						Labels:    map[string]string{"tier": "self-selected"},
+					},
+					Spec: v1alpha1.CloudflareAccessGroupSpec{
+						Name:    "self selector test " + name,
+						Include: []v1alpha1.CloudFlareAccessGroupRule{include},
+					},
+				}
+				Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+				return group
+			}
+
+			selected := newGroup("self-selected-member", v1alpha1.CloudFlareAccessGroupRule{Emails: []string{"self@domain.com"}})
+			selecting := newGroup("self-selecting", v1alpha1.CloudFlareAccessGroupRule{
+				AccessGroups: []v1alpha1.AccessGroup{{
+					Selector: &v1alpha1.ResourceSelector{
+						LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "self-selected"}},
+					},
+				}},
+			})
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: selected.Name, Namespace: namespace.Name}, selected)).To(Not(HaveOccurred()))
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: selecting.Name, Namespace: namespace.Name}, selecting)).To(Not(HaveOccurred()))
+				g.Expect(meta.FindStatusCondition(selecting.Status.Conditions, "Degraded")).To(BeNil())
+				g.Expect(selecting.Status.AccessGroupID).ToNot(BeEmpty())
+				cfGroup, err := api.AccessGroup(ctx, selecting.Status.AccessGroupID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(cfGroup.Include).To(HaveLen(1))
+				g.Expect(cfGroup.Include[0]).To(HaveKeyWithValue("group", HaveKeyWithValue("id", selected.Status.AccessGroupID)))
+			}, time.Second*20, time.Second).Should(Succeed())
+		})
+
+		It("should populate rule values from ConfigMaps and reconcile again when they change", func() {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "oncall",
+					Namespace: namespace.Name,
+				},
+				Data: map[string]string{
+					"emails": "# on-call rotation\noncall1@domain.com\noncall2@domain.com\n",
+				},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).To(Not(HaveOccurred()))
+
+			groupNamespaceName := types.NamespacedName{Name: "oncall", Namespace: namespace.Name}
+			group := &v1alpha1.CloudflareAccessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      groupNamespaceName.Name,
+					Namespace: groupNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessGroupSpec{
+					Name: "values from test",
+					Include: []v1alpha1.CloudFlareAccessGroupRule{{
+						Emails: []string{"oncall1@domain.com"},
+						EmailsFrom: []v1alpha1.RuleValuesSource{{
+							ConfigMapKeyRef: &v1alpha1.KeyReference{Namespace: namespace.Name, Name: configMap.Name, Key: "emails"},
+						}},
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, groupNamespaceName, group)).To(Not(HaveOccurred()))
+				g.Expect(group.Status.AccessGroupID).ToNot(BeEmpty())
+				cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(cfGroup.Include).To(HaveLen(2))
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			By("Changing the ConfigMap")
+			configMap.Data["emails"] = "oncall3@domain.com"
+			Expect(k8sClient.Update(ctx, configMap)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(cfGroup.Include).To(HaveLen(2))
+				g.Expect(cfGroup.Include[1]).To(HaveKeyWithValue("email", HaveKeyWithValue("email", "oncall3@domain.com")))
+			}, time.Second*20, time.Second).Should(Succeed())
+		})
+
+		It("should split oversized include lists into shards", func() {
+			maxRules := 2
+			groupNamespaceName := types.NamespacedName{Name: "sharded", Namespace: namespace.Name}
+			group := &v1alpha1.CloudflareAccessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      groupNamespaceName.Name,
+					Namespace: groupNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessGroupSpec{
+					Name:             "sharded test",
+					MaxRulesPerGroup: &maxRules,
+					Include: []v1alpha1.CloudFlareAccessGroupRule{{
+						Emails: []string{"1@domain.com", "2@domain.com", "3@domain.com", "4@domain.com", "5@domain.com"},
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, groupNamespaceName, group)).To(Not(HaveOccurred()))
+				g.Expect(group.Status.AccessGroupID).ToNot(BeEmpty())
+				g.Expect(group.Status.Shards).To(HaveLen(3))
+				cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(cfGroup.Include).To(HaveLen(3))
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			shards := group.Status.Shards
+			for _, shard := range shards {
+				cfShard, err := api.AccessGroup(ctx, shard.AccessGroupID)
+				Expect(err).To(Not(HaveOccurred()))
+				Expect(cfShard.Include).To(HaveLen(shard.RuleCount))
+			}
+
+			By("Shrinking the include list below the limit")
+			group.Spec.Include = []v1alpha1.CloudFlareAccessGroupRule{{Emails: []string{"1@domain.com"}}}
+			Expect(k8sClient.Update(ctx, group)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, groupNamespaceName, group)).To(Not(HaveOccurred()))
+				g.Expect(group.Status.Shards).To(BeEmpty())
+				cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(cfGroup.Include).To(HaveLen(1))
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			for _, shard := range shards {
+				_, err := api.AccessGroup(ctx, shard.AccessGroupID)
+				Expect(err).To(HaveOccurred())
+			}
+		})
 	})
 })
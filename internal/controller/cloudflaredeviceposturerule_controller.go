@@ -0,0 +1,218 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	v1alpha1 "github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfapi"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfcollections"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/config"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/ctrlhelper"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logger "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// CloudflareDevicePostureRuleReconciler reconciles a CloudflareDevicePostureRule object.
+type CloudflareDevicePostureRuleReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Helper *ctrlhelper.ControllerHelper
+}
+
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessgroups;cloudflareaccessapplications,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflaredeviceposturerules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflaredeviceposturerules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflaredeviceposturerules/finalizers,verbs=update
+
+//nolint:cyclop
+func (r *CloudflareDevicePostureRuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var err error
+	var existingRule *cloudflare.DevicePostureRule
+	var api *cfapi.API
+
+	log := logger.FromContext(ctx).WithName("CloudflareDevicePostureRuleController")
+
+	postureRule := &v1alpha1.CloudflareDevicePostureRule{}
+
+	err = r.Client.Get(ctx, req.NamespacedName, postureRule)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			forgetSynced("CloudflareDevicePostureRule", req.NamespacedName)
+
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "Failed to get CloudflareDevicePostureRule", "CloudflareDevicePostureRule.Name", req.Name)
+
+		return ctrl.Result{}, errors.Wrap(err, "Failed to get CloudflareDevicePostureRule")
+	}
+
+	cfConfig := config.ParseCloudflareConfig(postureRule)
+	validConfig, err := cfConfig.IsValid()
+	if !validConfig {
+		return ctrl.Result{}, errors.Wrap(err, "invalid config")
+	}
+
+	api, err = cfapi.New(cfConfig.APIToken, cfConfig.APIKey, cfConfig.APIEmail, cfConfig.AccountID)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to initialize cloudflare object")
+	}
+
+	continueReconcilliation, err := r.Helper.ReconcileDeletion(ctx, api, postureRule)
+	if !continueReconcilliation || err != nil {
+		if err != nil {
+			log.Error(err, "unable to reconcile deletion for device posture rule")
+		}
+
+		return ctrl.Result{}, errors.Wrap(err, "unable to reconcile deletion")
+	}
+
+	_, err = controllerutil.CreateOrPatch(ctx, r.Client, postureRule, func() error {
+		if len(postureRule.Status.Conditions) == 0 {
+			meta.SetStatusCondition(&postureRule.Status.Conditions, metav1.Condition{
+				Type:    statusAvailable,
+				Status:  metav1.ConditionUnknown,
+				Reason:  "Reconciling",
+				Message: "DevicePostureRule is reconciling",
+			})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareDevicePostureRule status")
+	}
+
+	if err := r.Helper.ReconcileDependents(ctx, postureRule); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to reconcile dependents")
+	}
+
+	if postureRule.Status.DevicePostureRuleID == "" {
+		cfRules, err := api.DevicePostureRules(ctx)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to get device posture rules")
+		}
+
+		existingRule = cfRules.GetByName(postureRule.Spec.Name)
+		if existingRule != nil {
+			log.Info("device posture rule already exists. importing...", "devicePostureRule", existingRule.Name, "devicePostureRuleID", existingRule.ID)
+		}
+
+		if err = r.ReconcileStatus(ctx, existingRule, postureRule); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to update device posture rule status")
+		}
+	} else {
+		cfRule, err := api.DevicePostureRule(ctx, postureRule.Status.DevicePostureRuleID)
+		if err != nil {
+			var apiErr *cloudflare.NotFoundError
+			if !errors.As(err, &apiErr) {
+				return ctrl.Result{}, errors.Wrap(err, "unable to get device posture rule")
+			}
+
+			log.Info("device posture rule not found - recreating...", "devicePostureRuleID", postureRule.Status.DevicePostureRuleID)
+			postureRule.Status.DevicePostureRuleID = ""
+		} else {
+			existingRule = &cfRule
+		}
+	}
+
+	if existingRule == nil {
+		rule, err := api.CreateDevicePostureRule(ctx, postureRule.ToCloudflare())
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to create device posture rule")
+		}
+
+		if err = r.ReconcileStatus(ctx, &rule, postureRule); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to set device posture rule status")
+		}
+
+		existingRule = &rule
+	}
+
+	desired := desiredState(postureRule.ToCloudflare())
+	if !cfcollections.DevicePostureRuleEqual(*existingRule, postureRule.ToCloudflare()) {
+		log.Info(postureRule.Spec.Name + " has changed, updating...")
+
+		if _, err := api.UpdateDevicePostureRule(ctx, postureRule.ToCloudflare()); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to update device posture rule")
+		}
+		recordDrift(postureRule, desired)
+	}
+
+	_, err = controllerutil.CreateOrPatch(ctx, r.Client, postureRule, func() error {
+		meta.SetStatusCondition(&postureRule.Status.Conditions, metav1.Condition{Type: statusAvailable, Status: metav1.ConditionTrue, Reason: "Reconciling", Message: "DevicePostureRule Reconciled Successfully"})
+
+		return nil
+	})
+
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareDevicePostureRule status")
+	}
+
+	log.Info("reconciled successfully")
+
+	return resyncResult(ctx, postureRule, desired), nil
+}
+
+func (r *CloudflareDevicePostureRuleReconciler) ReconcileStatus(ctx context.Context, cfRule *cloudflare.DevicePostureRule, k8sRule *v1alpha1.CloudflareDevicePostureRule) error {
+	if k8sRule.Status.DevicePostureRuleID != "" {
+		return nil
+	}
+
+	if cfRule == nil {
+		return nil
+	}
+
+	rule := k8sRule.DeepCopy()
+
+	if _, err := controllerutil.CreateOrPatch(ctx, r.Client, rule, func() error {
+		rule.Status.DevicePostureRuleID = cfRule.ID
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "Failed to update CloudflareDevicePostureRule status")
+	}
+
+	// CreateOrPatch re-fetches the object from k8s which removes any changes we've made that override them
+	// so thats why we re-apply these settings again on the original object;
+	k8sRule.Status = rule.Status
+
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CloudflareDevicePostureRuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	//nolint:wrapcheck
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.CloudflareDevicePostureRule{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha1.CloudflareAccessGroup{}, referrersHandler(r.Client, "CloudflareDevicePostureRule", func() client.ObjectList { return &v1alpha1.CloudflareDevicePostureRuleList{} }), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha1.CloudflareAccessApplication{}, referrersHandler(r.Client, "CloudflareDevicePostureRule", func() client.ObjectList { return &v1alpha1.CloudflareDevicePostureRuleList{} }), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
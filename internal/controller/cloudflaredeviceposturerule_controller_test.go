@@ -0,0 +1,172 @@
+//go:build integration
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("CloudflareDevicePostureRule controller", Ordered, func() {
+	BeforeAll(func() {
+		ctx := context.Background()
+
+		By("Removing all existing device posture rules")
+		rules, err := api.DevicePostureRules(ctx)
+		Expect(err).To(Not(HaveOccurred()))
+		for _, rule := range rules {
+			_ = api.DeleteDevicePostureRule(ctx, rule.ID)
+		}
+	})
+
+	Context("CloudflareDevicePostureRule controller test", func() {
+
+		const nsName = "deviceposture"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName,
+				Namespace: nsName,
+			},
+		}
+
+		BeforeEach(func() {
+			logOutput.Clear()
+
+			By("Creating the Namespace to perform the tests")
+			k8sClient.Create(ctx, namespace)
+		})
+
+		AfterEach(func() {
+			By("expect no reconcile errors occured")
+			Expect(logOutput.GetErrorCount()).To(Equal(0), logOutput.GetOutput())
+		})
+
+		It("should successfully reconcile a custom resource for CloudflareDevicePostureRule", func() {
+			typeNamespaceName := types.NamespacedName{Name: "firewall", Namespace: nsName}
+			enabled := true
+
+			By("Creating the custom resource for the Kind CloudflareDevicePostureRule")
+			rule := &v1alpha1.CloudflareDevicePostureRule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareDevicePostureRuleSpec{
+					Name: "integration firewall test",
+					Type: "firewall",
+					Match: []v1alpha1.DevicePostureRuleMatch{
+						{Platform: "windows"},
+					},
+					Input: v1alpha1.DevicePostureRuleInput{
+						Enabled: &enabled,
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, rule)).To(Not(HaveOccurred()))
+
+			By("Checking the latest Status should have the ID of the resource")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, rule)).ToNot(HaveOccurred())
+				g.Expect(rule.Status.DevicePostureRuleID).ToNot(BeEmpty())
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			By("Cloudflare resource should equal the spec")
+			cfRule, err := api.DevicePostureRule(ctx, rule.Status.DevicePostureRuleID)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(cfRule.Name).To(Equal(rule.Spec.Name))
+
+			By("Updating the name of the resource")
+			rule.Spec.Name = "updated firewall test"
+			Expect(k8sClient.Update(ctx, rule)).To(Not(HaveOccurred()))
+
+			Eventually(func() string {
+				cfRule, _ = api.DevicePostureRule(ctx, rule.Status.DevicePostureRuleID)
+
+				return cfRule.Name
+			}, time.Minute, time.Second).Should(Equal(rule.Spec.Name))
+		})
+
+		It("should successfully reference a CloudflareDevicePostureRule from a CloudflareAccessGroup", func() {
+			typeNamespaceName := types.NamespacedName{Name: "disk-encryption", Namespace: nsName}
+			requireAll := true
+
+			rule := &v1alpha1.CloudflareDevicePostureRule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareDevicePostureRuleSpec{
+					Name: "integration disk encryption test",
+					Type: "disk_encryption",
+					Match: []v1alpha1.DevicePostureRuleMatch{
+						{Platform: "mac"},
+					},
+					Input: v1alpha1.DevicePostureRuleInput{
+						RequireAll: &requireAll,
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, rule)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, rule)).ToNot(HaveOccurred())
+				g.Expect(rule.Status.DevicePostureRuleID).ToNot(BeEmpty())
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			group := &v1alpha1.CloudflareAccessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessGroupSpec{
+					Name: "device posture reference test group",
+					Include: []v1alpha1.CloudFlareAccessGroupRule{
+						{
+							Emails: []string{"test@cf-operator-tests.uk"},
+						},
+					},
+					Require: []v1alpha1.CloudFlareAccessGroupRule{
+						{
+							DevicePosture: []v1alpha1.DevicePosture{
+								{
+									ValueFrom: &v1alpha1.DevicePostureReference{
+										Name:      rule.Name,
+										Namespace: rule.Namespace,
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+			By("Checking the Status")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, group)).To(Not(HaveOccurred()))
+				g.Expect(group.Status.AccessGroupID).ToNot(BeEmpty())
+				g.Expect(group.Status.Conditions).ToNot(BeEmpty())
+				g.Expect(group.Status.Conditions[0].Status).To(Equal(metav1.ConditionTrue))
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			By("Cloudflare resource should require the device posture rule")
+			cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(cfGroup.Require).To(HaveLen(1))
+			Expect(cfGroup.Require[0]).To(HaveKeyWithValue("device_posture", HaveKeyWithValue("integration_uid", rule.Status.DevicePostureRuleID)))
+		})
+	})
+})
@@ -0,0 +1,350 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	v1alpha1 "github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfapi"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfcollections"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/config"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/ctrlhelper"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logger "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// CloudflareListReconciler reconciles a CloudflareList object.
+type CloudflareListReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Helper *ctrlhelper.ControllerHelper
+}
+
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessgroups;cloudflareaccessapplications,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflarelists,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflarelists/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflarelists/finalizers,verbs=update
+
+//nolint:cyclop,gocognit
+func (r *CloudflareListReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var err error
+	var existingList *cloudflare.TeamsList
+	var api *cfapi.API
+
+	log := logger.FromContext(ctx).WithName("CloudflareListController")
+
+	list := &v1alpha1.CloudflareList{}
+
+	err = r.Client.Get(ctx, req.NamespacedName, list)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			forgetSynced("CloudflareList", req.NamespacedName)
+
+			return ctrl.Result{}, nil
+		}
+
+		log.Error(err, "Failed to get CloudflareList", "CloudflareList.Name", req.Name)
+
+		return ctrl.Result{}, errors.Wrap(err, "Failed to get CloudflareList")
+	}
+
+	cfConfig := config.ParseCloudflareConfig(list)
+	validConfig, err := cfConfig.IsValid()
+	if !validConfig {
+		return ctrl.Result{}, errors.Wrap(err, "invalid config")
+	}
+
+	api, err = cfapi.New(cfConfig.APIToken, cfConfig.APIKey, cfConfig.APIEmail, cfConfig.AccountID)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to initialize cloudflare object")
+	}
+
+	continueReconcilliation, err := r.Helper.ReconcileDeletion(ctx, api, list)
+	if !continueReconcilliation || err != nil {
+		if err != nil {
+			log.Error(err, "unable to reconcile deletion for list")
+		}
+
+		return ctrl.Result{}, errors.Wrap(err, "unable to reconcile deletion")
+	}
+
+	_, err = controllerutil.CreateOrPatch(ctx, r.Client, list, func() error {
+		if len(list.Status.Conditions) == 0 {
+			meta.SetStatusCondition(&list.Status.Conditions, metav1.Condition{
+				Type:    statusAvailable,
+				Status:  metav1.ConditionUnknown,
+				Reason:  "Reconciling",
+				Message: "List is reconciling",
+			})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareList status")
+	}
+
+	if err := r.Helper.ReconcileDependents(ctx, list); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to reconcile dependents")
+	}
+
+	items, err := r.ResolveItems(ctx, list)
+	if err != nil {
+		_, err = controllerutil.CreateOrPatch(ctx, r.Client, list, func() error {
+			meta.SetStatusCondition(&list.Status.Conditions, metav1.Condition{Type: statusDegrated, Status: metav1.ConditionFalse, Reason: "InvalidReference", Message: err.Error()})
+
+			return nil
+		})
+
+		log.Info("failed to resolve list items")
+
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareList status")
+		}
+
+		// don't requeue; changes to the ConfigMaps will trigger a new reconcile
+		return ctrl.Result{}, nil
+	}
+
+	newList := list.ToCloudflare(items)
+
+	if list.Status.ListID == "" {
+		cfLists, err := api.TeamsLists(ctx)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to get lists")
+		}
+
+		existingList = cfLists.GetByName(list.Spec.Name)
+		if existingList != nil {
+			log.Info("list already exists. importing...", "list", existingList.Name, "listID", existingList.ID)
+		}
+
+		if err = r.ReconcileStatus(ctx, existingList, list); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to update list status")
+		}
+	} else {
+		cfList, err := api.TeamsList(ctx, list.Status.ListID)
+		if err != nil {
+			var apiErr *cloudflare.NotFoundError
+			if !errors.As(err, &apiErr) {
+				return ctrl.Result{}, errors.Wrap(err, "unable to get list")
+			}
+
+			log.Info("list not found - recreating...", "listID", list.Status.ListID)
+			list.Status.ListID = ""
+		} else {
+			existingList = &cfList
+		}
+	}
+
+	desired := desiredState(newList)
+	if existingList == nil {
+		cfList, err := api.CreateTeamsList(ctx, newList)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to create list")
+		}
+
+		if err = r.ReconcileStatus(ctx, &cfList, list); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to set list status")
+		}
+	} else {
+		newList.ID = existingList.ID
+		drifted := false
+
+		if !cfcollections.TeamsListEqual(*existingList, newList) {
+			log.Info(newList.Name + " has changed, updating...")
+			drifted = true
+
+			if _, err := api.UpdateTeamsList(ctx, newList); err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "unable to update list")
+			}
+		}
+
+		currentItems, err := api.TeamsListItems(ctx, existingList.ID)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to get list items")
+		}
+
+		add, remove := cfcollections.TeamsListItemsDiff(currentItems, newList.Items)
+		if len(add) > 0 || len(remove) > 0 {
+			log.Info(newList.Name+" items have changed, updating...", "added", len(add), "removed", len(remove))
+			drifted = true
+
+			if _, err := api.PatchTeamsListItems(ctx, existingList.ID, add, remove); err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "unable to update list items")
+			}
+		}
+
+		if drifted {
+			recordDrift(list, desired)
+		}
+	}
+
+	_, err = controllerutil.CreateOrPatch(ctx, r.Client, list, func() error {
+		list.Status.ItemCount = len(newList.Items)
+		meta.SetStatusCondition(&list.Status.Conditions, metav1.Condition{Type: statusAvailable, Status: metav1.ConditionTrue, Reason: "Reconciling", Message: "List Reconciled Successfully"})
+
+		return nil
+	})
+
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareList status")
+	}
+
+	log.Info("reconciled successfully")
+
+	return resyncResult(ctx, list, desired), nil
+}
+
+// ResolveItems merges the inline items with the items loaded from ConfigMaps; duplicates are removed.
+func (r *CloudflareListReconciler) ResolveItems(ctx context.Context, list *v1alpha1.CloudflareList) ([]string, error) {
+	items := []string{}
+	seen := map[string]struct{}{}
+
+	appendItem := func(item string) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			return
+		}
+		if _, ok := seen[item]; ok {
+			return
+		}
+		seen[item] = struct{}{}
+		items = append(items, item)
+	}
+
+	for _, item := range list.Spec.Items {
+		appendItem(item)
+	}
+
+	for _, source := range list.Spec.ItemsFrom {
+		if source.ConfigMapKeyRef == nil {
+			continue
+		}
+
+		ref := source.ConfigMapKeyRef
+		optional := ref.Optional != nil && *ref.Optional
+
+		configMap := &corev1.ConfigMap{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: list.Namespace, Name: ref.Name}, configMap); err != nil {
+			if k8serrors.IsNotFound(err) && optional {
+				continue
+			}
+
+			return nil, errors.Wrapf(err, "unable to reference ConfigMap %s - %s", ref.Name, list.Namespace)
+		}
+
+		value, ok := configMap.Data[ref.Key]
+		if !ok {
+			if optional {
+				continue
+			}
+
+			return nil, errors.Errorf("key %s not found in ConfigMap %s - %s", ref.Key, ref.Name, list.Namespace)
+		}
+
+		for _, item := range strings.Split(value, "\n") {
+			appendItem(item)
+		}
+	}
+
+	return items, nil
+}
+
+func (r *CloudflareListReconciler) ReconcileStatus(ctx context.Context, cfList *cloudflare.TeamsList, k8sList *v1alpha1.CloudflareList) error {
+	if k8sList.Status.ListID != "" {
+		return nil
+	}
+
+	if cfList == nil {
+		return nil
+	}
+
+	list := k8sList.DeepCopy()
+
+	if _, err := controllerutil.CreateOrPatch(ctx, r.Client, list, func() error {
+		list.Status.ListID = cfList.ID
+		if cfList.CreatedAt != nil {
+			list.Status.CreatedAt = metav1.NewTime(*cfList.CreatedAt)
+		}
+		if cfList.UpdatedAt != nil {
+			list.Status.UpdatedAt = metav1.NewTime(*cfList.UpdatedAt)
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "Failed to update CloudflareList status")
+	}
+
+	// CreateOrPatch re-fetches the object from k8s which removes any changes we've made that override them
+	// so thats why we re-apply these settings again on the original object;
+	k8sList.Status = list.Status
+
+	return nil
+}
+
+// findListsForConfigMap returns the CloudflareLists that load their items from the given ConfigMap.
+func (r *CloudflareListReconciler) findListsForConfigMap(ctx context.Context, configMap client.Object) []reconcile.Request {
+	lists := &v1alpha1.CloudflareListList{}
+	if err := r.Client.List(ctx, lists, client.InNamespace(configMap.GetNamespace())); err != nil {
+		logger.FromContext(ctx).Error(err, "unable to list CloudflareLists")
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, list := range lists.Items {
+		for _, source := range list.Spec.ItemsFrom {
+			if source.ConfigMapKeyRef != nil && source.ConfigMapKeyRef.Name == configMap.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: list.Namespace, Name: list.Name}})
+
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CloudflareListReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	//nolint:wrapcheck
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.CloudflareList{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.findListsForConfigMap)).
+		Watches(&v1alpha1.CloudflareAccessGroup{}, referrersHandler(r.Client, "CloudflareList", func() client.ObjectList { return &v1alpha1.CloudflareListList{} }), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha1.CloudflareAccessApplication{}, referrersHandler(r.Client, "CloudflareList", func() client.ObjectList { return &v1alpha1.CloudflareListList{} }), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
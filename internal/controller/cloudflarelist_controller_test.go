@@ -0,0 +1,214 @@
+//go:build integration
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("CloudflareList controller", Ordered, func() {
+	BeforeAll(func() {
+		ctx := context.Background()
+
+		By("Removing all existing lists")
+		lists, err := api.TeamsLists(ctx)
+		Expect(err).To(Not(HaveOccurred()))
+		for _, list := range lists {
+			_ = api.DeleteTeamsList(ctx, list.ID)
+		}
+	})
+
+	Context("CloudflareList controller test", func() {
+
+		const nsName = "lists"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName,
+				Namespace: nsName,
+			},
+		}
+
+		BeforeEach(func() {
+			logOutput.Clear()
+
+			By("Creating the Namespace to perform the tests")
+			k8sClient.Create(ctx, namespace)
+		})
+
+		AfterEach(func() {
+			By("expect no reconcile errors occured")
+			Expect(logOutput.GetErrorCount()).To(Equal(0), logOutput.GetOutput())
+		})
+
+		It("should successfully reconcile a custom resource for CloudflareList", func() {
+			typeNamespaceName := types.NamespacedName{Name: "emails", Namespace: nsName}
+
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "list-emails",
+					Namespace: nsName,
+				},
+				Data: map[string]string{
+					"emails": "# comment\nuser2@cf-operator-tests.uk\n\nuser3@cf-operator-tests.uk\n",
+				},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).To(Not(HaveOccurred()))
+
+			By("Creating the custom resource for the Kind CloudflareList")
+			list := &v1alpha1.CloudflareList{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareListSpec{
+					Name:  "integration email list",
+					Type:  v1alpha1.ListTypeEmail,
+					Items: []string{"user1@cf-operator-tests.uk", "user2@cf-operator-tests.uk"},
+					ItemsFrom: []v1alpha1.ListItemsSource{
+						{
+							ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: configMap.Name},
+								Key:                  "emails",
+							},
+						},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, list)).To(Not(HaveOccurred()))
+
+			By("Checking the latest Status should have the ID of the resource")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, list)).ToNot(HaveOccurred())
+				g.Expect(list.Status.ListID).ToNot(BeEmpty())
+				g.Expect(list.Status.ItemCount).To(Equal(3))
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			By("Cloudflare resource should contain the merged items")
+			items, err := api.TeamsListItems(ctx, list.Status.ListID)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(items).To(HaveLen(3))
+
+			By("Updating the ConfigMap")
+			configMap.Data["emails"] = "user4@cf-operator-tests.uk"
+			Expect(k8sClient.Update(ctx, configMap)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				items, err := api.TeamsListItems(ctx, list.Status.ListID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(items).To(HaveLen(3))
+				g.Expect(items).To(ContainElement(HaveField("Value", "user4@cf-operator-tests.uk")))
+			}, time.Minute, time.Second).Should(Succeed())
+		})
+
+		It("should successfully reference a CloudflareList from a CloudflareAccessGroup", func() {
+			typeNamespaceName := types.NamespacedName{Name: "ips", Namespace: nsName}
+
+			list := &v1alpha1.CloudflareList{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareListSpec{
+					Name:  "integration ip list",
+					Type:  v1alpha1.ListTypeIP,
+					Items: []string{"10.0.0.1/32"},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, list)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, list)).ToNot(HaveOccurred())
+				g.Expect(list.Status.ListID).ToNot(BeEmpty())
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			group := &v1alpha1.CloudflareAccessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessGroupSpec{
+					Name: "list reference test group",
+					Include: []v1alpha1.CloudFlareAccessGroupRule{
+						{
+							IPList: []v1alpha1.List{
+								{
+									ValueFrom: &v1alpha1.ListReference{
+										Name:      list.Name,
+										Namespace: list.Namespace,
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, group)).To(Not(HaveOccurred()))
+
+			By("Checking the Status")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, group)).To(Not(HaveOccurred()))
+				g.Expect(group.Status.AccessGroupID).ToNot(BeEmpty())
+				g.Expect(group.Status.Conditions).ToNot(BeEmpty())
+				g.Expect(group.Status.Conditions[0].Status).To(Equal(metav1.ConditionTrue))
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			By("Cloudflare resource should include the list")
+			cfGroup, err := api.AccessGroup(ctx, group.Status.AccessGroupID)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(cfGroup.Include).To(HaveLen(1))
+			Expect(cfGroup.Include[0]).To(HaveKeyWithValue("ip_list", HaveKeyWithValue("id", list.Status.ListID)))
+		})
+
+		It("should revert items removed in cloudflare on resync", func() {
+			typeNamespaceName := types.NamespacedName{Name: "resync", Namespace: nsName}
+
+			list := &v1alpha1.CloudflareList{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        typeNamespaceName.Name,
+					Namespace:   typeNamespaceName.Namespace,
+					Annotations: map[string]string{v1alpha1.AnnotationResyncInterval: "2s"},
+				},
+				Spec: v1alpha1.CloudflareListSpec{
+					Name:  "integration resync list",
+					Type:  v1alpha1.ListTypeEmail,
+					Items: []string{"resync1@cf-operator-tests.uk", "resync2@cf-operator-tests.uk"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, list)).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, list)).ToNot(HaveOccurred())
+				g.Expect(list.Status.ListID).ToNot(BeEmpty())
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			By("Removing an item in cloudflare")
+			_, err := api.PatchTeamsListItems(ctx, list.Status.ListID, nil, []string{"resync2@cf-operator-tests.uk"})
+			Expect(err).To(Not(HaveOccurred()))
+
+			Eventually(func(g Gomega) {
+				items, err := api.TeamsListItems(ctx, list.Status.ListID)
+				g.Expect(err).To(Not(HaveOccurred()))
+				g.Expect(items).To(ContainElement(HaveField("Value", "resync2@cf-operator-tests.uk")))
+			}, time.Second*30, time.Second).Should(Succeed())
+
+			Expect(testutil.ToFloat64(driftTotal.WithLabelValues("CloudflareList"))).To(BeNumerically(">=", 1))
+
+			Expect(k8sClient.Delete(ctx, list)).To(Not(HaveOccurred()))
+		})
+	})
+})
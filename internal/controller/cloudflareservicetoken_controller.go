@@ -18,35 +18,76 @@ package controller
 
 import (
 	"context"
+	"sort"
+	"strings"
+	"time"
 
 	v1alpha1 "github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfapi"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cftypes"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/config"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/ctrlhelper"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/secretsinks"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/services"
+	cloudflare "github.com/cloudflare/cloudflare-go"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logger "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
+var errReplicaConflict = errors.New("secret already exists and isn't a replica of the service token")
+
+const (
+	// reasonTokenMissing is the reason used when a token was deleted in cloudflare.
+	reasonTokenMissing = "TokenMissing"
+	// reasonSecretMissing is the reason used when the secret of a token was deleted.
+	reasonSecretMissing = "SecretMissing"
+	// reasonDrift is the reason used when the token in cloudflare doesn't match the spec.
+	reasonDrift = "Drift"
+	// reasonInSync is the reason used when the token in cloudflare matches the spec.
+	reasonInSync = "InSync"
+
+	// reasonInvalidTemplate is the reason used when the secret template can't be rendered.
+	reasonInvalidTemplate = "InvalidTemplate"
+	// reasonSecretStoreChanged is the reason used when the credentials can't be moved to a changed secret store.
+	reasonSecretStoreChanged = "SecretStoreChanged"
+
+	// statusDrifted is the condition reporting differences between the spec and the token in cloudflare.
+	statusDrifted = "Drifted"
+
+	// statusExpiringSoon, statusExpired and statusStale report the expiry and the use of the token.
+	statusExpiringSoon = "ExpiringSoon"
+	statusExpired      = "Expired"
+	statusStale        = "Stale"
+	// reasonValid is the reason used when the token isn't expiring, expired or stale.
+	reasonValid = "Valid"
+)
+
 // CloudflareServiceTokenReconciler reconciles a CloudflareServiceToken object.
 type CloudflareServiceTokenReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	Helper *ctrlhelper.ControllerHelper
+	Scheme   *runtime.Scheme
+	Helper   *ctrlhelper.ControllerHelper
+	Recorder record.EventRecorder
 }
 
+// +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareaccessgroups;cloudflareaccessapplications,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;patch
 // +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareservicetokens,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareservicetokens/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cloudflare.zelic.io,resources=cloudflareservicetokens/finalizers,verbs=update
@@ -65,6 +106,9 @@ func (r *CloudflareServiceTokenReconciler) Reconcile(ctx context.Context, req ct
 
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
+			serviceTokenMetricsCollector.Delete(req.NamespacedName)
+			forgetSynced("CloudflareServiceToken", req.NamespacedName)
+
 			return ctrl.Result{}, nil
 		}
 
@@ -91,6 +135,10 @@ func (r *CloudflareServiceTokenReconciler) Reconcile(ctx context.Context, req ct
 			log.Error(err, "unable to reconcile deletion for service token")
 		}
 
+		if serviceToken.UnderDeletion() {
+			serviceTokenMetricsCollector.Delete(req.NamespacedName)
+		}
+
 		return ctrl.Result{}, errors.Wrap(err, "unable to reconcile deletion")
 	}
 
@@ -111,33 +159,58 @@ func (r *CloudflareServiceTokenReconciler) Reconcile(ctx context.Context, req ct
 		return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareServiceToken status")
 	}
 
-	// this is used just for populating existingServiceToken
-	secretList := &corev1.SecretList{}
-	if err := r.Client.List(ctx, secretList,
-		client.MatchingLabels{v1alpha1.LabelOwnedBy: serviceToken.Name},
-		client.InNamespace(serviceToken.Namespace),
-	); err != nil {
-		return ctrl.Result{}, errors.Wrap(err, "unable to list created secrets")
+	if err := r.Helper.ReconcileDependents(ctx, serviceToken); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to reconcile dependents")
 	}
 
-	secret := &corev1.Secret{}
+	// the templates are validated before the token is created or renewed; new credentials can't be stored otherwise
+	if _, err := serviceToken.Spec.Template.RenderData(cftypes.ExtendedServiceToken{}); err != nil {
+		log.Info("invalid secret template", "error", err.Error())
 
-	if len(secretList.Items) > 0 {
-		// we already have a secret created
-		secret = &secretList.Items[0]
+		if _, err := controllerutil.CreateOrPatch(ctx, r.Client, serviceToken, func() error {
+			meta.SetStatusCondition(&serviceToken.Status.Conditions, metav1.Condition{Type: statusDegrated, Status: metav1.ConditionTrue, Reason: reasonInvalidTemplate, Message: err.Error()})
 
-		if len(secretList.Items) > 1 {
-			log.Info("Found multiple secrets with the same owner label", "label", v1alpha1.LabelOwnedBy, "owner", serviceToken.Name)
+			return nil
+		}); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareServiceToken status")
 		}
+
+		// don't requeue until the template is changed
+		return ctrl.Result{}, nil
+	}
+
+	sink, err := secretsinks.New(ctx, r.Client, serviceToken)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to initialize secret sink")
+	}
+
+	// this is used just for populating existingServiceToken
+	stored, err := sink.Read(ctx)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to read stored credentials")
 	}
 
-	if !secret.CreationTimestamp.IsZero() {
+	// the credentials are moved when the secret store changed, the token would be recreated as missing otherwise
+	var previousSink secretsinks.Sink
+	if stored == nil && serviceToken.Status.ServiceTokenID != "" {
+		if previousSink, err = secretsinks.Previous(ctx, r.Client, serviceToken); err != nil {
+			return ctrl.Result{}, r.RefuseSecretStoreChange(ctx, serviceToken, err)
+		}
+
+		if previousSink != nil {
+			if stored, err = previousSink.Read(ctx); err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "unable to read credentials of the previous secret store")
+			}
+		}
+	}
+
+	if stored != nil {
 		allTokens, err := api.ServiceTokens(ctx)
 		if err != nil {
 			return ctrl.Result{}, errors.Wrap(err, "unable to create access service token")
 		}
 		for i, token := range allTokens {
-			if token.ID == string(secret.Data[secret.Annotations[v1alpha1.AnnotationTokenIDKey]]) {
+			if token.ID == stored.TokenID {
 				existingServiceToken = &allTokens[i]
 
 				break
@@ -145,6 +218,139 @@ func (r *CloudflareServiceTokenReconciler) Reconcile(ctx context.Context, req ct
 		}
 	}
 
+	// the stored credentials reference a token which was deleted in cloudflare
+	missingToken := existingServiceToken == nil && stored != nil
+	// the stored credentials of a previously created token were deleted
+	missingSecret := stored == nil && serviceToken.Status.ServiceTokenID != ""
+
+	if missingToken || missingSecret {
+		continueReconcilliation, err := r.ReconcileMissing(ctx, api, serviceToken, missingToken)
+		if !continueReconcilliation || err != nil {
+			// don't requeue when the token is not recreated
+			return ctrl.Result{}, err
+		}
+	}
+
+	// the name and duration are updated before a renewal so that the token is refreshed with the new duration
+	drift := []string{}
+	desired := desiredState(serviceToken.Spec.Name, serviceToken.Spec.Duration)
+	if existingServiceToken != nil {
+		if drift = serviceToken.Drift(*existingServiceToken); len(drift) > 0 {
+			existingServiceToken, err = r.ReconcileDrift(ctx, api, serviceToken, *existingServiceToken, drift)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			recordDrift(serviceToken, desired)
+		}
+	}
+
+	// the previous token of an overlapping rotation stays valid until the end of the overlap window
+	previous := previousCredentials(serviceToken, stored)
+	overlapEndsAt := serviceToken.Status.OverlapEndsAt
+	if previous.PreviousTokenID != "" && overlapEndsAt == nil {
+		window, err := serviceToken.Spec.GetOverlapWindow()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		overlapEnd := metav1.NewTime(time.Now().Add(window))
+		overlapEndsAt = &overlapEnd
+	}
+	if previous.PreviousTokenID != "" && overlapEndsAt != nil && !time.Now().Before(overlapEndsAt.Time) {
+		if err := r.DeletePreviousServiceToken(ctx, api, previous.PreviousTokenID); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		previous, overlapEndsAt = secretsinks.Credentials{}, nil
+	}
+
+	// a token created by this reconciliation only exists in cloudflare until its credentials are stored; it's deleted
+	// if they can't be, otherwise every retry would leave another token behind
+	unstoredTokenID := ""
+	discardUnstored := func(err error) (ctrl.Result, error) {
+		if unstoredTokenID == "" {
+			return ctrl.Result{}, err
+		}
+
+		if deleteErr := api.DeleteAccessServiceToken(ctx, unstoredTokenID); deleteErr != nil {
+			log.Error(deleteErr, "unable to delete access service token with unstored credentials", "token_id", unstoredTokenID)
+		} else {
+			log.Info("deleted access service token with unstored credentials", "token_id", unstoredTokenID)
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	renewed := false
+	if existingServiceToken != nil {
+		needsRenewal, err := serviceToken.NeedsRenewal(time.Now())
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to determine service token renewal")
+		}
+
+		//nolint:nestif
+		if needsRenewal && serviceToken.Spec.RotationStrategy == v1alpha1.RotationStrategyOverlap {
+			window, err := serviceToken.Spec.GetOverlapWindow()
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+
+			// a previous token still overlapping is replaced by the current one
+			if previous.PreviousTokenID != "" {
+				if err := r.DeletePreviousServiceToken(ctx, api, previous.PreviousTokenID); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+
+			successor, err := api.CreateAccessServiceToken(ctx, serviceToken.ToExtendedToken())
+			if err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "unable to create successor access service token")
+			}
+			unstoredTokenID = successor.ID
+
+			previous = secretsinks.Credentials{
+				PreviousTokenID:      existingServiceToken.ID,
+				PreviousClientID:     stored.ClientID,
+				PreviousClientSecret: stored.ClientSecret,
+			}
+			overlapEnd := metav1.NewTime(time.Now().Add(window))
+			overlapEndsAt = &overlapEnd
+
+			log.Info("created successor access service token", "token_id", successor.ID, "previous_token_id", existingServiceToken.ID)
+			existingServiceToken = &successor
+			renewed = true
+		} else if needsRenewal {
+			existingServiceToken, err = r.RenewServiceToken(ctx, api, *existingServiceToken)
+			if err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "unable to renew access service token")
+			}
+
+			log.Info("renewed access service token", "token_id", existingServiceToken.ID)
+			renewed = true
+		}
+	}
+
+	// a rotation requested with the annotation rotates the client secret in place, regardless of the strategy;
+	// a token that was just renewed or created already has fresh credentials. It isn't a renewal: the expiry is
+	// unchanged, so it must not postpone the renewal of the token
+	rotationRequest, rotationRequested := serviceToken.RotationRequested()
+	rotated := false
+	if rotationRequested && existingServiceToken != nil && !renewed {
+		rotatedToken, err := api.RotateAccessServiceToken(ctx, *existingServiceToken)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to rotate access service token")
+		}
+
+		existingServiceToken.ClientSecret = rotatedToken.ClientSecret
+		if rotatedToken.UpdatedAt != nil {
+			existingServiceToken.UpdatedAt = rotatedToken.UpdatedAt
+		}
+
+		log.Info("rotated access service token on request", "token_id", existingServiceToken.ID, "request", rotationRequest)
+		rotated = true
+	}
+
+	created := false
 	if existingServiceToken == nil {
 		token, err := api.CreateAccessServiceToken(ctx, serviceToken.ToExtendedToken())
 		log.Info("created access service token", "token_id", token.ID)
@@ -152,123 +358,527 @@ func (r *CloudflareServiceTokenReconciler) Reconcile(ctx context.Context, req ct
 		if err != nil {
 			return ctrl.Result{}, errors.Wrap(err, "unable to create access service token")
 		}
+		unstoredTokenID = token.ID
+		created = true
+	}
+
+	// a created, renewed or rotated token has new credentials which must not be overwritten by the stale stored ones
+	if stored != nil && !renewed && !rotated && !created {
+		existingServiceToken.ClientID = stored.ClientID
+		existingServiceToken.ClientSecret = stored.ClientSecret
+	}
+
+	templateData, err := serviceToken.Spec.Template.RenderData(*existingServiceToken)
+	if err != nil {
+		return discardUnstored(errors.Wrap(err, "unable to render secret template"))
 	}
 
-	// update object with secret ref
-	if !secret.CreationTimestamp.IsZero() {
-		if err := existingServiceToken.SetSecretValues(*secret); err != nil {
-			return ctrl.Result{}, errors.Wrap(err, "failed to set secret")
+	credentials := secretsinks.Credentials{
+		TokenID:              existingServiceToken.ID,
+		ClientID:             existingServiceToken.ClientID,
+		ClientSecret:         existingServiceToken.ClientSecret,
+		Data:                 templateData,
+		PreviousTokenID:      previous.PreviousTokenID,
+		PreviousClientID:     previous.PreviousClientID,
+		PreviousClientSecret: previous.PreviousClientSecret,
+	}
+
+	secretRef, err := sink.Write(ctx, credentials)
+	if err != nil {
+		return discardUnstored(errors.Wrap(err, "unable to store credentials"))
+	}
+
+	if previousSink != nil && stored != nil {
+		log.Info("moved credentials to the new secret store", "sink", secretRef.Sink)
+
+		if err := previousSink.Delete(ctx); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "unable to remove credentials of the previous secret store")
+		}
+	}
+
+	// only kubernetes secrets are replicated; the secret and replicas left from a previous sink are removed
+	var secret *corev1.Secret
+	if kubernetesSink, ok := sink.(*secretsinks.KubernetesSink); ok {
+		secret = kubernetesSink.Secret
+	} else if err := (&secretsinks.KubernetesSink{Client: r.Client, Token: serviceToken}).Delete(ctx); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to remove secret of previous sink")
+	}
+
+	// replication errors are reported in the status first and returned afterwards to retry
+	replicas, replicationErr := r.ReconcileReplicas(ctx, serviceToken, secret)
+
+	// workloads are only restarted when the credentials changed since the last reconciliation
+	credentialsHash := credentials.Hash()
+	if serviceToken.Spec.RestartWorkloads && serviceToken.Status.CredentialsHash != "" && serviceToken.Status.CredentialsHash != credentialsHash {
+		if err := r.RestartWorkloads(ctx, serviceToken, secret, replicas, credentialsHash); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	err = r.ReconcileStatus(ctx, existingServiceToken, secretRef, serviceToken)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to set status")
+	}
+
+	var reported []metav1.Condition
+	if _, err := controllerutil.CreateOrPatch(ctx, r.Client, serviceToken, func() error {
+		meta.SetStatusCondition(&serviceToken.Status.Conditions, metav1.Condition{Type: statusAvailable, Status: metav1.ConditionTrue, Reason: "Reconciling", Message: "CloudflareServiceToken Reconciled Successfully"})
+
+		if condition := meta.FindStatusCondition(serviceToken.Status.Conditions, statusDegrated); condition != nil &&
+			(condition.Reason == reasonTokenMissing || condition.Reason == reasonSecretMissing || condition.Reason == reasonInvalidTemplate || condition.Reason == reasonSecretStoreChanged) {
+			meta.RemoveStatusCondition(&serviceToken.Status.Conditions, statusDegrated)
+		}
+
+		if len(drift) > 0 {
+			meta.SetStatusCondition(&serviceToken.Status.Conditions, metav1.Condition{Type: statusDrifted, Status: metav1.ConditionFalse, Reason: reasonInSync, Message: "corrected drift: " + strings.Join(drift, ", ")})
+		} else if meta.FindStatusCondition(serviceToken.Status.Conditions, statusDrifted) == nil {
+			meta.SetStatusCondition(&serviceToken.Status.Conditions, metav1.Condition{Type: statusDrifted, Status: metav1.ConditionFalse, Reason: reasonInSync, Message: "service token matches the spec"})
+		}
+
+		serviceToken.Status.Replicas = replicas
+		serviceToken.Status.CredentialsHash = credentialsHash
+		serviceToken.Status.PreviousServiceTokenID = previous.PreviousTokenID
+		serviceToken.Status.OverlapEndsAt = overlapEndsAt
+
+		if renewed {
+			now := metav1.Now()
+			serviceToken.Status.LastRotatedAt = &now
+			serviceToken.Status.RotationCount++
+		}
+
+		if rotationRequested {
+			serviceToken.Status.LastRotationRequest = rotationRequest
+		}
+
+		if rotated {
+			now := metav1.Now()
+			serviceToken.Status.LastRotationRequestedAt = &now
 		}
+
+		var err error
+		reported, err = setExpiryConditions(serviceToken, time.Now())
+
+		return err
+	}); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareServiceToken status")
+	}
+
+	if rotated {
+		r.Recorder.Eventf(serviceToken, corev1.EventTypeNormal, "Rotated", "Rotated the credentials of service token %s on request %q", serviceToken.Status.ServiceTokenID, rotationRequest)
+	}
+
+	for _, condition := range reported {
+		r.Recorder.Event(serviceToken, corev1.EventTypeWarning, condition.Type, condition.Message)
+	}
+
+	lastSeenAt := time.Time{}
+	if serviceToken.Status.LastSeenAt != nil {
+		lastSeenAt = serviceToken.Status.LastSeenAt.Time
+	}
+	serviceTokenMetricsCollector.Set(req.NamespacedName, serviceToken.Status.ExpiresAt.Time, lastSeenAt)
+
+	if serviceToken.Spec.DeleteStale && meta.IsStatusConditionTrue(serviceToken.Status.Conditions, statusStale) {
+		log.Info("deleting stale service token", "token_id", serviceToken.Status.ServiceTokenID)
+		r.Recorder.Event(serviceToken, corev1.EventTypeWarning, "DeletedStale", "Deleting the service token as it wasn't used for "+serviceToken.Spec.StaleAfter)
+
+		return ctrl.Result{}, errors.Wrap(client.IgnoreNotFound(r.Client.Delete(ctx, serviceToken)), "unable to delete stale service token")
+	}
+
+	if replicationErr != nil {
+		return ctrl.Result{}, replicationErr
+	}
+
+	nextCheck, err := serviceToken.NextCheck(time.Now())
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to determine service token renewal")
+	}
+
+	result := resyncResult(ctx, serviceToken, desired)
+	if !nextCheck.IsZero() {
+		result = earliest(result, ctrl.Result{RequeueAfter: time.Until(nextCheck)})
+	}
+
+	return result, nil
+}
+
+// setExpiryConditions sets the ExpiringSoon, Expired and Stale conditions of the token. Returns the conditions which
+// became true.
+func setExpiryConditions(serviceToken *v1alpha1.CloudflareServiceToken, now time.Time) ([]metav1.Condition, error) {
+	expiringAt, err := serviceToken.ExpiringAt()
+	if err != nil {
+		return nil, err
+	}
+
+	staleAt, staleEnabled, err := serviceToken.StaleAt()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := serviceToken.Status.ExpiresAt
+	expired := !expiresAt.IsZero() && !now.Before(expiresAt.Time)
+	expiringSoon := !expiresAt.IsZero() && !expired && !now.Before(expiringAt)
+
+	conditions := []metav1.Condition{
+		{Type: statusExpired, Status: metav1.ConditionFalse, Reason: reasonValid, Message: "service token is valid"},
+		{Type: statusExpiringSoon, Status: metav1.ConditionFalse, Reason: reasonValid, Message: "service token isn't expiring soon"},
 	}
 
-	// reconcile  secret
-	secretNamespacedName := types.NamespacedName{
-		Namespace: req.Namespace,
-		Name:      req.Name,
+	if expired {
+		conditions[0] = metav1.Condition{Type: statusExpired, Status: metav1.ConditionTrue, Reason: statusExpired, Message: "service token " + serviceToken.Status.ServiceTokenID + " expired at " + expiresAt.UTC().Format(time.RFC3339)}
 	}
 
-	if serviceToken.Spec.Template.Name != "" {
-		secretNamespacedName.Name = serviceToken.Spec.Template.Name
+	if expiringSoon {
+		conditions[1] = metav1.Condition{Type: statusExpiringSoon, Status: metav1.ConditionTrue, Reason: statusExpiringSoon, Message: "service token " + serviceToken.Status.ServiceTokenID + " expires at " + expiresAt.UTC().Format(time.RFC3339)}
 	}
 
-	var secretToDelete *corev1.Secret
-	// secret exists & was renamed; remove the old one
-	if !secret.CreationTimestamp.IsZero() && secretNamespacedName.Name != secret.Name {
-		secretToDelete = secret
+	if staleEnabled {
+		condition := metav1.Condition{Type: statusStale, Status: metav1.ConditionFalse, Reason: reasonValid, Message: "service token was used in the last " + serviceToken.Spec.StaleAfter}
+		if !now.Before(staleAt) {
+			condition = metav1.Condition{Type: statusStale, Status: metav1.ConditionTrue, Reason: statusStale, Message: "service token " + serviceToken.Status.ServiceTokenID + " wasn't used for " + serviceToken.Spec.StaleAfter}
+		}
+		conditions = append(conditions, condition)
+	} else {
+		meta.RemoveStatusCondition(&serviceToken.Status.Conditions, statusStale)
 	}
 
-	secret = &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretNamespacedName.Name,
-			Namespace: secretNamespacedName.Namespace,
-		},
+	reported := []metav1.Condition{}
+	for _, condition := range conditions {
+		if condition.Status == metav1.ConditionTrue && !meta.IsStatusConditionTrue(serviceToken.Status.Conditions, condition.Type) {
+			reported = append(reported, condition)
+		}
+
+		meta.SetStatusCondition(&serviceToken.Status.Conditions, condition)
 	}
 
-	secretAnnotations := map[string]string{
-		v1alpha1.AnnotationClientIDKey:     serviceToken.Spec.Template.ClientIDKey,
-		v1alpha1.AnnotationClientSecretKey: serviceToken.Spec.Template.ClientSecretKey,
-		v1alpha1.AnnotationTokenIDKey:      "serviceTokenID",
+	return reported, nil
+}
+
+// ReconcileMissing handles a token deleted in cloudflare or a secret deleted in kubernetes. Unless recreateMissing is
+// disabled, the reconciliation continues and creates a token with fresh credentials; a token whose client secret
+// was lost with the secret is removed from cloudflare. Otherwise the resource is marked as Degraded and the
+// reconciliation stops.
+func (r *CloudflareServiceTokenReconciler) ReconcileMissing(ctx context.Context, api *cfapi.API, serviceToken *v1alpha1.CloudflareServiceToken, missingToken bool) (bool, error) {
+	log := logger.FromContext(ctx)
+
+	reason := reasonSecretMissing
+	message := "the secret of service token " + serviceToken.Status.ServiceTokenID + " is missing"
+	if missingToken {
+		reason = reasonTokenMissing
+		message = "service token " + serviceToken.Status.ServiceTokenID + " no longer exists in cloudflare"
 	}
 
-	if serviceToken.Spec.Template.Annotations != nil {
-		for annotationKey, annotationValue := range serviceToken.Spec.Template.Annotations {
-			if _, exists := secretAnnotations[annotationKey]; !exists {
-				secretAnnotations[annotationKey] = annotationValue
+	if !serviceToken.Spec.GetRecreateMissing() {
+		log.Info(message + " and recreateMissing is disabled")
+
+		if _, err := controllerutil.CreateOrPatch(ctx, r.Client, serviceToken, func() error {
+			meta.SetStatusCondition(&serviceToken.Status.Conditions, metav1.Condition{Type: statusAvailable, Status: metav1.ConditionFalse, Reason: reason, Message: message})
+			meta.SetStatusCondition(&serviceToken.Status.Conditions, metav1.Condition{Type: statusDegrated, Status: metav1.ConditionTrue, Reason: reason, Message: message + " and recreateMissing is disabled"})
+
+			return nil
+		}); err != nil {
+			return false, errors.Wrap(err, "Failed to update CloudflareServiceToken status")
+		}
+
+		return false, nil
+	}
+
+	log.Info(message + " - recreating...")
+
+	if !missingToken {
+		if err := api.DeleteAccessServiceToken(ctx, serviceToken.Status.ServiceTokenID); err != nil {
+			var notFound *cloudflare.NotFoundError
+			if !errors.As(err, &notFound) {
+				return false, errors.Wrap(err, "unable to delete access service token without secret")
 			}
 		}
 	}
 
-	secretLabels := map[string]string{
-		v1alpha1.LabelOwnedBy: serviceToken.Name,
+	return true, nil
+}
+
+// RefuseSecretStoreChange marks the token as degraded when its credentials can't be read from the previous secret
+// store; the token isn't recreated so that the consumers of the current credentials keep working.
+func (r *CloudflareServiceTokenReconciler) RefuseSecretStoreChange(ctx context.Context, serviceToken *v1alpha1.CloudflareServiceToken, cause error) error {
+	message := "unable to move the credentials to the new secret store: " + cause.Error()
+	logger.FromContext(ctx).Info(message)
+
+	if _, err := controllerutil.CreateOrPatch(ctx, r.Client, serviceToken, func() error {
+		meta.SetStatusCondition(&serviceToken.Status.Conditions, metav1.Condition{Type: statusDegrated, Status: metav1.ConditionTrue, Reason: reasonSecretStoreChanged, Message: message})
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "Failed to update CloudflareServiceToken status")
 	}
 
-	if serviceToken.Spec.Template.Labels != nil {
-		for labelKey, labelValue := range serviceToken.Spec.Template.Labels {
-			if _, exists := secretLabels[labelKey]; !exists {
-				secretLabels[labelKey] = labelValue
+	// don't requeue until the secret store is changed back
+	return nil
+}
+
+// ReconcileReplicas copies the secret into the namespaces targeted by the replication spec and removes the
+// replicas which are no longer targeted. The returned status holds the result for every targeted namespace.
+// All replicas are removed if secret is nil.
+func (r *CloudflareServiceTokenReconciler) ReconcileReplicas(ctx context.Context, serviceToken *v1alpha1.CloudflareServiceToken, secret *corev1.Secret) ([]v1alpha1.SecretReplicaStatus, error) {
+	log := logger.FromContext(ctx)
+
+	namespaces := []string{}
+	replicaLabels := map[string]string{}
+
+	if secret != nil {
+		var err error
+		if namespaces, err = r.replicaNamespaces(ctx, serviceToken); err != nil {
+			return serviceToken.Status.Replicas, err
+		}
+
+		for key, value := range secret.Labels {
+			if key != v1alpha1.LabelOwnedBy {
+				replicaLabels[key] = value
 			}
 		}
 	}
+	for key, value := range serviceToken.ReplicaLabels() {
+		replicaLabels[key] = value
+	}
 
-	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
-		secret.SetLabels(secretLabels)
-		secret.SetAnnotations(secretAnnotations)
+	var replicationErr error
+	replicas := make([]v1alpha1.SecretReplicaStatus, 0, len(namespaces))
+	targets := map[string]bool{}
 
-		secret.Data = map[string][]byte{}
-		secret.Data[serviceToken.Spec.Template.ClientSecretKey] = []byte(existingServiceToken.ClientSecret)
-		secret.Data[serviceToken.Spec.Template.ClientIDKey] = []byte(existingServiceToken.ClientID)
-		secret.Data["serviceTokenID"] = []byte(existingServiceToken.ID)
+	for _, namespace := range namespaces {
+		targets[namespace] = true
+		status := v1alpha1.SecretReplicaStatus{Namespace: namespace, Synced: true}
 
-		if err := existingServiceToken.SetSecretValues(*secret); err != nil {
-			return errors.Wrap(err, "unable to CreateOrUpdate Secret")
+		replica := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secret.Name,
+				Namespace: namespace,
+			},
 		}
 
-		if err := ctrl.SetControllerReference(serviceToken, secret, r.Scheme); err != nil {
-			return errors.Wrap(err, "unable to set secret owner reference")
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			_, err := controllerutil.CreateOrUpdate(ctx, r.Client, replica, func() error {
+				// never take over a secret which wasn't replicated from this token
+				if !replica.CreationTimestamp.IsZero() && (replica.Labels[v1alpha1.LabelReplicaOf] != serviceToken.Name ||
+					replica.Labels[v1alpha1.LabelReplicaOfNamespace] != serviceToken.Namespace) {
+					return errReplicaConflict
+				}
+
+				replica.SetLabels(replicaLabels)
+				replica.SetAnnotations(secret.Annotations)
+				replica.Type = secret.Type
+				replica.Data = secret.Data
+
+				return nil
+			})
+
+			//nolint:wrapcheck
+			return err
+		})
+
+		if err != nil {
+			status.Synced = false
+			status.Message = err.Error()
+
+			if errors.Is(err, errReplicaConflict) {
+				log.Info("secret already exists and isn't a replica", "namespace", namespace, "secret", secret.Name)
+			} else {
+				log.Error(err, "unable to replicate secret", "namespace", namespace)
+				replicationErr = errors.Wrapf(err, "unable to replicate secret into %s", namespace)
+			}
 		}
 
-		return nil
-	})
-	if err != nil {
-		return ctrl.Result{}, errors.Wrap(err, "Failed to create/update Secret")
+		replicas = append(replicas, status)
 	}
-	if op == controllerutil.OperationResultCreated {
-		log.Info("created secret")
-	} else if op == controllerutil.OperationResultUpdated {
-		log.Info("updated secret")
+
+	existing := &corev1.SecretList{}
+	if err := r.Client.List(ctx, existing, client.MatchingLabels(serviceToken.ReplicaLabels())); err != nil {
+		return replicas, errors.Wrap(err, "unable to list secret replicas")
 	}
 
-	if secretToDelete != nil {
-		if err := r.Client.Delete(ctx, secretToDelete); err != nil {
-			log.Error(nil, "failed to remove old secret")
-		} else {
-			log.Info("removed old secret")
+	for i, replica := range existing.Items {
+		if secret != nil && targets[replica.Namespace] && replica.Name == secret.Name {
+			continue
+		}
+
+		if err := r.Client.Delete(ctx, &existing.Items[i]); client.IgnoreNotFound(err) != nil {
+			return replicas, errors.Wrapf(err, "unable to remove secret replica in %s", replica.Namespace)
 		}
+
+		log.Info("removed secret replica", "namespace", replica.Namespace, "secret", replica.Name)
 	}
 
-	if err := existingServiceToken.SetSecretValues(*secret); err != nil {
-		return ctrl.Result{}, errors.Wrap(err, "failed to set secret")
+	return replicas, replicationErr
+}
+
+// RestartWorkloads rolls the workloads consuming the token in its namespace and in the namespaces of the synced
+// replicas. Without secret, only the workloads labelled to opt in are restarted.
+func (r *CloudflareServiceTokenReconciler) RestartWorkloads(ctx context.Context, serviceToken *v1alpha1.CloudflareServiceToken, secret *corev1.Secret, replicas []v1alpha1.SecretReplicaStatus, hash string) error {
+	log := logger.FromContext(ctx)
+
+	secretName := ""
+	if secret != nil {
+		secretName = secret.Name
 	}
 
-	existingServiceToken.SetSecretReference(serviceToken.Spec.Template.ClientIDKey, serviceToken.Spec.Template.ClientSecretKey, *secret)
+	namespaces := []string{serviceToken.Namespace}
+	for _, replica := range replicas {
+		if replica.Synced {
+			namespaces = append(namespaces, replica.Namespace)
+		}
+	}
 
-	err = r.ReconcileStatus(ctx, existingServiceToken, serviceToken)
-	if err != nil {
-		return ctrl.Result{}, errors.Wrap(err, "unable to set status")
+	for _, namespace := range namespaces {
+		workloads, err := services.ConsumingWorkloads(ctx, r.Client, namespace, serviceToken.Name, secretName)
+		if err != nil {
+			return errors.Wrap(err, "unable to find consuming workloads")
+		}
+
+		for _, workload := range workloads {
+			restarted, err := services.RestartWorkload(ctx, r.Client, workload, hash)
+			if err != nil {
+				return errors.Wrap(err, "unable to restart consuming workload")
+			}
+
+			if restarted {
+				log.Info("restarted consuming workload", "kind", workload.Kind, "namespace", namespace, "name", workload.Object.GetName())
+				r.Recorder.Eventf(serviceToken, corev1.EventTypeNormal, "RestartedWorkload", "Restarted %s %s/%s after the credentials changed", workload.Kind, namespace, workload.Object.GetName())
+			}
+		}
+	}
+
+	return nil
+}
+
+// replicaNamespaces returns the sorted namespaces targeted by the replication spec, excluding the namespace of the token.
+func (r *CloudflareServiceTokenReconciler) replicaNamespaces(ctx context.Context, serviceToken *v1alpha1.CloudflareServiceToken) ([]string, error) {
+	replication := serviceToken.Spec.Replication
+	if replication == nil {
+		return nil, nil
 	}
 
+	targets := map[string]bool{}
+	for _, namespace := range replication.Namespaces {
+		targets[namespace] = true
+	}
+
+	if replication.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(replication.NamespaceSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid namespaceSelector")
+		}
+
+		namespaceList := &corev1.NamespaceList{}
+		if err := r.Client.List(ctx, namespaceList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, errors.Wrap(err, "unable to list namespaces")
+		}
+
+		for _, namespace := range namespaceList.Items {
+			if namespace.DeletionTimestamp.IsZero() {
+				targets[namespace.Name] = true
+			}
+		}
+	}
+
+	delete(targets, serviceToken.Namespace)
+
+	namespaces := make([]string, 0, len(targets))
+	for namespace := range targets {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
+
+// ReconcileDrift reports the differences between the spec and the token in cloudflare in the Drifted condition
+// and updates the name and duration of the token in place.
+func (r *CloudflareServiceTokenReconciler) ReconcileDrift(ctx context.Context, api *cfapi.API, serviceToken *v1alpha1.CloudflareServiceToken, token cftypes.ExtendedServiceToken, drift []string) (*cftypes.ExtendedServiceToken, error) {
+	log := logger.FromContext(ctx)
+	message := strings.Join(drift, ", ")
+
+	log.Info("service token drifted from spec - updating...", "token_id", token.ID, "drift", message)
+
 	if _, err := controllerutil.CreateOrPatch(ctx, r.Client, serviceToken, func() error {
-		meta.SetStatusCondition(&serviceToken.Status.Conditions, metav1.Condition{Type: statusAvailable, Status: metav1.ConditionTrue, Reason: "Reconciling", Message: "CloudflareServiceToken Reconciled Successfully"})
+		meta.SetStatusCondition(&serviceToken.Status.Conditions, metav1.Condition{Type: statusDrifted, Status: metav1.ConditionTrue, Reason: reasonDrift, Message: message})
 
 		return nil
 	}); err != nil {
-		return ctrl.Result{}, errors.Wrap(err, "Failed to update CloudflareServiceToken status")
+		return nil, errors.Wrap(err, "Failed to update CloudflareServiceToken status")
+	}
+
+	token.Name = serviceToken.Spec.Name
+	if serviceToken.Spec.Duration != "" {
+		token.Duration = serviceToken.Spec.Duration
+	}
+
+	updated, err := api.UpdateAccessServiceToken(ctx, token)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to update access service token")
+	}
+
+	return &updated, nil
+}
+
+// previousCredentials returns the stored credentials of the previous token tracked in the status, or only in the
+// stored credentials if the status couldn't be updated after they were written.
+func previousCredentials(serviceToken *v1alpha1.CloudflareServiceToken, stored *secretsinks.Credentials) secretsinks.Credentials {
+	previous := secretsinks.Credentials{PreviousTokenID: serviceToken.Status.PreviousServiceTokenID}
+	if previous.PreviousTokenID == "" && stored != nil {
+		previous.PreviousTokenID = stored.PreviousTokenID
+	}
+
+	if stored != nil && stored.PreviousTokenID == previous.PreviousTokenID {
+		previous.PreviousClientID = stored.PreviousClientID
+		previous.PreviousClientSecret = stored.PreviousClientSecret
 	}
 
-	return ctrl.Result{}, nil
+	return previous
 }
 
-func (r *CloudflareServiceTokenReconciler) ReconcileStatus(ctx context.Context, cfToken *cftypes.ExtendedServiceToken, k8sToken *v1alpha1.CloudflareServiceToken) error {
+// DeletePreviousServiceToken removes the previous token of an overlapping rotation from cloudflare.
+func (r *CloudflareServiceTokenReconciler) DeletePreviousServiceToken(ctx context.Context, api *cfapi.API, tokenID string) error {
+	if err := api.DeleteAccessServiceToken(ctx, tokenID); err != nil {
+		var notFound *cloudflare.NotFoundError
+		if !errors.As(err, &notFound) {
+			return errors.Wrap(err, "unable to delete previous access service token")
+		}
+	}
+
+	logger.FromContext(ctx).Info("removed previous access service token", "token_id", tokenID)
+
+	return nil
+}
+
+// RenewServiceToken extends the expiration of the token and rotates its client secret.
+func (r *CloudflareServiceTokenReconciler) RenewServiceToken(ctx context.Context, api *cfapi.API, token cftypes.ExtendedServiceToken) (*cftypes.ExtendedServiceToken, error) {
+	refreshed, err := api.RefreshAccessServiceToken(ctx, token)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to refresh access service token")
+	}
+
+	rotated, err := api.RotateAccessServiceToken(ctx, token)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to rotate access service token")
+	}
+
+	renewed := token
+	renewed.ClientSecret = rotated.ClientSecret
+
+	if refreshed.ExpiresAt != nil {
+		renewed.ExpiresAt = refreshed.ExpiresAt
+	}
+
+	if rotated.ExpiresAt != nil && (renewed.ExpiresAt == nil || rotated.ExpiresAt.After(*renewed.ExpiresAt)) {
+		renewed.ExpiresAt = rotated.ExpiresAt
+	}
+
+	if rotated.UpdatedAt != nil {
+		renewed.UpdatedAt = rotated.UpdatedAt
+	}
+
+	return &renewed, nil
+}
+
+func (r *CloudflareServiceTokenReconciler) ReconcileStatus(ctx context.Context, cfToken *cftypes.ExtendedServiceToken, secretRef v1alpha1.SecretRef, k8sToken *v1alpha1.CloudflareServiceToken) error {
 	if cfToken == nil {
 		return nil
 	}
@@ -280,13 +890,12 @@ func (r *CloudflareServiceTokenReconciler) ReconcileStatus(ctx context.Context,
 		token.Status.CreatedAt = metav1.NewTime(*cfToken.CreatedAt)
 		token.Status.UpdatedAt = metav1.NewTime(*cfToken.UpdatedAt)
 		token.Status.ExpiresAt = metav1.NewTime(*cfToken.ExpiresAt)
-		token.Status.SecretRef = &v1alpha1.SecretRef{
-			LocalObjectReference: corev1.LocalObjectReference{
-				Name: cfToken.K8sSecretRef.SecretName,
-			},
-			ClientSecretKey: cfToken.K8sSecretRef.ClientSecretKey,
-			ClientIDKey:     cfToken.K8sSecretRef.ClientIDKey,
+		token.Status.Duration = cfToken.Duration
+		if cfToken.LastSeenAt != nil {
+			lastSeenAt := metav1.NewTime(*cfToken.LastSeenAt)
+			token.Status.LastSeenAt = &lastSeenAt
 		}
+		token.Status.SecretRef = &secretRef
 
 		return nil
 	}); err != nil {
@@ -304,7 +913,11 @@ func (r *CloudflareServiceTokenReconciler) ReconcileStatus(ctx context.Context,
 func (r *CloudflareServiceTokenReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	//nolint:wrapcheck
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.CloudflareServiceToken{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		For(&v1alpha1.CloudflareServiceToken{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
 		Owns(&corev1.Secret{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(requestsForSecretReplica)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(requestsForReplicaNamespace(r.Client)), builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Watches(&v1alpha1.CloudflareAccessGroup{}, referrersHandler(r.Client, "CloudflareServiceToken", func() client.ObjectList { return &v1alpha1.CloudflareServiceTokenList{} }), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha1.CloudflareAccessApplication{}, referrersHandler(r.Client, "CloudflareServiceToken", func() client.ObjectList { return &v1alpha1.CloudflareServiceTokenList{} }), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
@@ -9,12 +9,16 @@ import (
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
 
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cftypes"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/secretsinks/vaulttest"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("CloudflareServiceToken controller", Ordered, func() {
@@ -412,5 +416,750 @@ var _ = Describe("CloudflareServiceToken controller", Ordered, func() {
 			Expect(foundToken).ToNot(BeNil())
 		})
 
+		It("should list dependents and block deletion while referenced", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token6", Namespace: nsName}
+			groupNamespaceName := types.NamespacedName{Name: "token6-group", Namespace: nsName}
+
+			By("Creating a service token which blocks deletion while referenced")
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+					Annotations: map[string]string{
+						v1alpha1.AnnotationBlockDeletionWithDependents: "true",
+					},
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name: "integration servicetoken test6",
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			By("Creating an access group referencing the service token")
+			group := &v1alpha1.CloudflareAccessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      groupNamespaceName.Name,
+					Namespace: groupNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareAccessGroupSpec{
+					Name: "integration servicetoken test6 group",
+					Include: []v1alpha1.CloudFlareAccessGroupRule{{
+						ServiceToken: []v1alpha1.ServiceToken{{
+							ValueFrom: &v1alpha1.ServiceTokenReference{Namespace: nsName, Name: typeNamespaceName.Name},
+						}},
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, group)).To(Succeed())
+
+			By("Expecting the access group in the dependents of the service token")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(token.Status.Dependents).To(Equal([]v1alpha1.ResourceReference{
+					{Kind: "CloudflareAccessGroup", Namespace: nsName, Name: groupNamespaceName.Name},
+				}))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Removing the service token while it is still referenced")
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+
+			Consistently(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Finalizers).To(ContainElement(v1alpha1.FinalizerDeletion))
+			}, time.Second*3, time.Second).Should(Succeed())
+
+			By("Removing the access group")
+			Expect(k8sClient.Delete(ctx, group)).To(Succeed())
+
+			By("Expecting the service token to be removed")
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, typeNamespaceName, token))
+			}, time.Second*10, time.Second).Should(BeTrue())
+		})
+
+		It("should renew the token inside the renewal window", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token7", Namespace: nsName}
+
+			By("Creating a service token")
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name: "integration servicetoken test7",
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(token.Status.ExpiresAt.IsZero()).To(BeFalse())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			previousSecret := string(secret.Data[token.Status.SecretRef.ClientSecretKey])
+			Expect(token.Status.RotationCount).To(Equal(0))
+
+			By("Moving the token inside its renewal window")
+			// a renewal window exceeding the lifetime of the token only renews once
+			token.Spec.MinTimeBeforeRenewal = "87600h"
+			Expect(k8sClient.Update(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.RotationCount).To(Equal(1))
+				g.Expect(token.Status.LastRotatedAt).ToNot(BeNil())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+				g.Expect(string(secret.Data[token.Status.SecretRef.ClientSecretKey])).ToNot(Equal(previousSecret))
+				g.Expect(string(secret.Data["serviceTokenID"])).To(Equal(token.Status.ServiceTokenID))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			Consistently(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.RotationCount).To(Equal(1))
+			}, time.Second*3, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+		})
+
+		It("should recreate a token deleted in cloudflare with fresh credentials", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token8", Namespace: nsName}
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name: "integration servicetoken test8",
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			previousID := token.Status.ServiceTokenID
+			previousSecret := string(secret.Data[token.Status.SecretRef.ClientSecretKey])
+
+			By("Externally removing the token")
+			Expect(api.DeleteAccessServiceToken(ctx, previousID)).To(Succeed())
+
+			By("Triggering a reconciliation through the secret")
+			secret.Labels["test"] = "vanished"
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(Equal(previousID))
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+				g.Expect(string(secret.Data["serviceTokenID"])).To(Equal(token.Status.ServiceTokenID))
+				g.Expect(string(secret.Data[token.Status.SecretRef.ClientSecretKey])).ToNot(Equal(previousSecret))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+		})
+
+		It("should mark the token as degraded when recreateMissing is disabled", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token9", Namespace: nsName}
+			recreateMissing := false
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name:            "integration servicetoken test9",
+					RecreateMissing: &recreateMissing,
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			previousID := token.Status.ServiceTokenID
+
+			By("Externally removing the token")
+			Expect(api.DeleteAccessServiceToken(ctx, previousID)).To(Succeed())
+
+			By("Triggering a reconciliation through the secret")
+			secret.Labels["test"] = "vanished"
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				condition := meta.FindStatusCondition(token.Status.Conditions, "Degraded")
+				g.Expect(condition).ToNot(BeNil())
+				g.Expect(condition.Reason).To(Equal("TokenMissing"))
+				g.Expect(token.Status.ServiceTokenID).To(Equal(previousID))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Enabling recreateMissing")
+			recreateMissing = true
+			token.Spec.RecreateMissing = &recreateMissing
+			Expect(k8sClient.Update(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(meta.FindStatusCondition(token.Status.Conditions, "Degraded")).To(BeNil())
+				g.Expect(token.Status.ServiceTokenID).ToNot(Equal(previousID))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+		})
+
+		It("should rename the token and apply its duration in place", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token10", Namespace: nsName}
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name:     "integration servicetoken test10",
+					Duration: "24h",
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(token.Status.Duration).To(Equal("24h"))
+				g.Expect(token.Status.ExpiresAt.Time).To(BeTemporally("<", time.Now().Add(25*time.Hour)))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			previousID := token.Status.ServiceTokenID
+
+			By("Changing the name and duration")
+			token.Spec.Name = "integration servicetoken test10 renamed"
+			token.Spec.Duration = "48h"
+			Expect(k8sClient.Update(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).To(Equal(previousID))
+				g.Expect(token.Status.Duration).To(Equal("48h"))
+				condition := meta.FindStatusCondition(token.Status.Conditions, "Drifted")
+				g.Expect(condition).ToNot(BeNil())
+				g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+				g.Expect(condition.Message).To(ContainSubstring("name"))
+				g.Expect(condition.Message).To(ContainSubstring("duration"))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			tokens, err := api.ServiceTokens(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			found := false
+			for _, cfToken := range tokens {
+				if cfToken.ID == previousID {
+					found = true
+					Expect(cfToken.Name).To(Equal("integration servicetoken test10 renamed"))
+				}
+			}
+			Expect(found).To(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+		})
+
+		It("should render the templated secret entries", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token11", Namespace: nsName}
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name: "integration servicetoken test11",
+					Template: v1alpha1.SecretTemplateSpec{
+						ClientIDKey:     "cloudflareClientId",
+						ClientSecretKey: "cloudflareSecretKey",
+						Data: map[string]string{
+							"CF-Access-Client-Id": "{{ .Unknown }}",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			By("Rejecting an invalid template before creating the token")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				condition := meta.FindStatusCondition(token.Status.Conditions, "Degraded")
+				g.Expect(condition).ToNot(BeNil())
+				g.Expect(condition.Reason).To(Equal("InvalidTemplate"))
+				g.Expect(token.Status.ServiceTokenID).To(BeEmpty())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Fixing the template")
+			token.Spec.Template.Data = map[string]string{
+				"CF-Access-Client-Id": "{{ .ClientID }}",
+				"token.json":          `{"id": {{ json .TokenID }}}`,
+			}
+			Expect(k8sClient.Update(ctx, token)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(meta.FindStatusCondition(token.Status.Conditions, "Degraded")).To(BeNil())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+				g.Expect(secret.Data["CF-Access-Client-Id"]).To(Equal(secret.Data["cloudflareClientId"]))
+				g.Expect(string(secret.Data["token.json"])).To(MatchJSON(`{"id": "` + token.Status.ServiceTokenID + `"}`))
+				g.Expect(secret.Data).To(HaveKey("cloudflareSecretKey"))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+		})
+
+		It("should replicate the secret into other namespaces", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token12", Namespace: nsName}
+
+			By("Creating the target namespaces")
+			explicit := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "servicetoken-replica-a"}}
+			selected := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "servicetoken-replica-b", Labels: map[string]string{"servicetoken": "shared"}}}
+			Expect(k8sClient.Create(ctx, explicit)).To(Succeed())
+			Expect(k8sClient.Create(ctx, selected)).To(Succeed())
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name: "integration servicetoken test12",
+					Replication: &v1alpha1.SecretReplicationSpec{
+						Namespaces: []string{explicit.Name},
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"servicetoken": "shared"},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.Replicas).To(ConsistOf(
+					v1alpha1.SecretReplicaStatus{Namespace: explicit.Name, Synced: true},
+					v1alpha1.SecretReplicaStatus{Namespace: selected.Name, Synced: true},
+				))
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+
+				for _, namespace := range []string{explicit.Name, selected.Name} {
+					replica := &corev1.Secret{}
+					g.Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: secret.Name}, replica)).To(Succeed())
+					g.Expect(replica.Data).To(Equal(secret.Data))
+					g.Expect(replica.Labels).ToNot(HaveKey(v1alpha1.LabelOwnedBy))
+				}
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Keeping the replicas in sync")
+			replica := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: explicit.Name, Name: secret.Name}, replica)).To(Succeed())
+			replica.Data[token.Status.SecretRef.ClientSecretKey] = []byte("tampered")
+			Expect(k8sClient.Update(ctx, replica)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: explicit.Name, Name: secret.Name}, replica)).To(Succeed())
+				g.Expect(replica.Data).To(Equal(secret.Data))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Removing the replica of a namespace which stopped matching")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: selected.Name}, selected)).To(Succeed())
+			selected.Labels = nil
+			Expect(k8sClient.Update(ctx, selected)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.Replicas).To(ConsistOf(v1alpha1.SecretReplicaStatus{Namespace: explicit.Name, Synced: true}))
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: selected.Name, Name: secret.Name}, &corev1.Secret{})
+				g.Expect(errors.IsNotFound(err)).To(BeTrue())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Removing the replicas with the token")
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Namespace: explicit.Name, Name: secret.Name}, &corev1.Secret{}))
+			}, time.Second*10, time.Second).Should(BeTrue())
+		})
+
+		It("should store the credentials in vault", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token13", Namespace: nsName}
+
+			server := vaulttest.NewServer("root")
+			defer server.Close()
+
+			vaultToken := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault-token", Namespace: nsName},
+				StringData: map[string]string{"token": "root"},
+			}
+			Expect(k8sClient.Create(ctx, vaultToken)).To(Succeed())
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name: "integration servicetoken test13",
+					Template: v1alpha1.SecretTemplateSpec{
+						ClientIDKey:     "cloudflareClientId",
+						ClientSecretKey: "cloudflareSecretKey",
+					},
+					SecretStore: &v1alpha1.SecretStoreSpec{
+						Vault: &v1alpha1.VaultStoreSpec{
+							Address: server.URL,
+							Mount:   "secret",
+							Path:    "cloudflare/token13",
+							TokenSecretRef: corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: vaultToken.Name},
+								Key:                  "token",
+							},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(token.Status.SecretRef).ToNot(BeNil())
+				g.Expect(token.Status.SecretRef.Sink).To(Equal("Vault"))
+				g.Expect(token.Status.SecretRef.Vault.Path).To(Equal("cloudflare/token13"))
+
+				stored := server.Secret("secret/cloudflare/token13")
+				g.Expect(stored).To(HaveKeyWithValue("serviceTokenID", token.Status.ServiceTokenID))
+				g.Expect(stored).To(HaveKey("cloudflareClientId"))
+				g.Expect(stored).To(HaveKey("cloudflareSecretKey"))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Not creating a kubernetes secret")
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespaceName, &corev1.Secret{}))).To(BeTrue())
+
+			By("Removing the credentials with the token")
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+
+			Eventually(func() map[string]interface{} {
+				return server.Secret("secret/cloudflare/token13")
+			}, time.Second*10, time.Second).Should(BeNil())
+
+			Expect(k8sClient.Delete(ctx, vaultToken)).To(Succeed())
+		})
+
+		It("should overlap the previous and successor token during a rotation", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token14", Namespace: nsName}
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name:             "integration servicetoken test14",
+					RotationStrategy: v1alpha1.RotationStrategyOverlap,
+					OverlapWindow:    "5s",
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			previousID := token.Status.ServiceTokenID
+			previousClientID := string(secret.Data[token.Status.SecretRef.ClientIDKey])
+			previousSecret := string(secret.Data[token.Status.SecretRef.ClientSecretKey])
+
+			By("Moving the token inside its renewal window")
+			token.Spec.MinTimeBeforeRenewal = "87600h"
+			Expect(k8sClient.Update(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(Equal(previousID))
+				g.Expect(token.Status.PreviousServiceTokenID).To(Equal(previousID))
+				g.Expect(token.Status.OverlapEndsAt).ToNot(BeNil())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+				g.Expect(string(secret.Data["serviceTokenID"])).To(Equal(token.Status.ServiceTokenID))
+				g.Expect(string(secret.Data["previous-serviceTokenID"])).To(Equal(previousID))
+				g.Expect(string(secret.Data["previous-"+token.Status.SecretRef.ClientIDKey])).To(Equal(previousClientID))
+				g.Expect(string(secret.Data["previous-"+token.Status.SecretRef.ClientSecretKey])).To(Equal(previousSecret))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Removing the previous token after the overlap window")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.PreviousServiceTokenID).To(BeEmpty())
+				g.Expect(token.Status.OverlapEndsAt).To(BeNil())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+				g.Expect(secret.Data).ToNot(HaveKey("previous-serviceTokenID"))
+
+				tokens, err := api.ServiceTokens(ctx)
+				g.Expect(err).ToNot(HaveOccurred())
+				for _, cfToken := range tokens {
+					g.Expect(cfToken.ID).ToNot(Equal(previousID))
+				}
+			}, time.Second*20, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+		})
+
+		It("should rotate the token on request", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token15", Namespace: nsName}
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name: "integration servicetoken test15",
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			previousID := token.Status.ServiceTokenID
+			previousSecret := string(secret.Data[token.Status.SecretRef.ClientSecretKey])
+
+			By("Requesting a rotation")
+			token.Annotations = map[string]string{v1alpha1.AnnotationRotationRequest: "incident-1"}
+			Expect(k8sClient.Update(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.LastRotationRequest).To(Equal("incident-1"))
+				g.Expect(token.Status.ServiceTokenID).To(Equal(previousID))
+				g.Expect(token.Status.LastRotationRequestedAt).ToNot(BeNil())
+				g.Expect(token.Status.RotationCount).To(Equal(0))
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+				g.Expect(string(secret.Data[token.Status.SecretRef.ClientSecretKey])).ToNot(Equal(previousSecret))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				events := &corev1.EventList{}
+				g.Expect(k8sClient.List(ctx, events, client.InNamespace(nsName))).To(Succeed())
+
+				reasons := []string{}
+				for _, event := range events.Items {
+					if event.InvolvedObject.Name == typeNamespaceName.Name {
+						reasons = append(reasons, event.Reason)
+					}
+				}
+				g.Expect(reasons).To(ContainElement("Rotated"))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Not rotating again for the same request")
+			rotatedAt := token.Status.LastRotationRequestedAt
+			Consistently(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.LastRotationRequestedAt).To(Equal(rotatedAt))
+			}, time.Second*3, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+		})
+
+		It("should restart the workloads consuming the token when its credentials change", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token16", Namespace: nsName}
+
+			deployment := func(name string, labels map[string]string, env []corev1.EnvVar) *appsv1.Deployment {
+				return &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: nsName, Labels: labels},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+						Template: corev1.PodTemplateSpec{
+							ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+							Spec: corev1.PodSpec{
+								Containers: []corev1.Container{{Name: "app", Image: "nginx", Env: env}},
+							},
+						},
+					},
+				}
+			}
+
+			consumer := deployment("token16-consumer", nil, []corev1.EnvVar{{
+				Name: "CF_ACCESS_CLIENT_SECRET",
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: typeNamespaceName.Name},
+					Key:                  "cloudflareSecretKey",
+				}},
+			}})
+			labelled := deployment("token16-labelled", map[string]string{v1alpha1.LabelRestartOnRotation: typeNamespaceName.Name}, nil)
+			unrelated := deployment("token16-unrelated", nil, nil)
+			for _, workload := range []*appsv1.Deployment{consumer, labelled, unrelated} {
+				Expect(k8sClient.Create(ctx, workload)).To(Succeed())
+			}
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name:             "integration servicetoken test16",
+					RestartWorkloads: true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.CredentialsHash).ToNot(BeEmpty())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Not restarting the workloads for a new token")
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(consumer), consumer)).To(Succeed())
+			Expect(consumer.Spec.Template.Annotations).ToNot(HaveKey(v1alpha1.AnnotationCredentialsHash))
+
+			By("Rotating the token")
+			previousHash := token.Status.CredentialsHash
+			token.Annotations = map[string]string{v1alpha1.AnnotationRotationRequest: "restart"}
+			Expect(k8sClient.Update(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.CredentialsHash).ToNot(Equal(previousHash))
+
+				for _, workload := range []*appsv1.Deployment{consumer, labelled} {
+					g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workload), workload)).To(Succeed())
+					g.Expect(workload.Spec.Template.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationCredentialsHash, token.Status.CredentialsHash))
+				}
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(unrelated), unrelated)).To(Succeed())
+			Expect(unrelated.Spec.Template.Annotations).ToNot(HaveKey(v1alpha1.AnnotationCredentialsHash))
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+			for _, workload := range []*appsv1.Deployment{consumer, labelled, unrelated} {
+				Expect(k8sClient.Delete(ctx, workload)).To(Succeed())
+			}
+		})
+
+		It("should report the token as expiring soon inside the expiry warning", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token17", Namespace: nsName}
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name:          "integration servicetoken test17",
+					ExpiryWarning: "87600h",
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(meta.IsStatusConditionTrue(token.Status.Conditions, statusExpiringSoon)).To(BeTrue())
+				g.Expect(meta.IsStatusConditionFalse(token.Status.Conditions, statusExpired)).To(BeTrue())
+				g.Expect(meta.FindStatusCondition(token.Status.Conditions, statusStale)).To(BeNil())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+		})
+
+		It("should move the credentials when the secret store changes", func() {
+			typeNamespaceName := types.NamespacedName{Name: "token18", Namespace: nsName}
+
+			server := vaulttest.NewServer("root")
+			defer server.Close()
+
+			vaultToken := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault-token18", Namespace: nsName},
+				StringData: map[string]string{"token": "root"},
+			}
+			Expect(k8sClient.Create(ctx, vaultToken)).To(Succeed())
+
+			token := &v1alpha1.CloudflareServiceToken{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespaceName.Name,
+					Namespace: typeNamespaceName.Namespace,
+				},
+				Spec: v1alpha1.CloudflareServiceTokenSpec{
+					Name: "integration servicetoken test18",
+				},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.ServiceTokenID).ToNot(BeEmpty())
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, secret)).To(Succeed())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			tokenID := token.Status.ServiceTokenID
+			clientSecret := string(secret.Data[token.Status.SecretRef.ClientSecretKey])
+
+			By("Switching to vault")
+			token.Spec.SecretStore = &v1alpha1.SecretStoreSpec{
+				Vault: &v1alpha1.VaultStoreSpec{
+					Address: server.URL,
+					Mount:   "secret",
+					Path:    "cloudflare/token18",
+					TokenSecretRef: corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: vaultToken.Name},
+						Key:                  "token",
+					},
+				},
+			}
+			Expect(k8sClient.Update(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				g.Expect(token.Status.SecretRef.Sink).To(Equal("Vault"))
+				g.Expect(token.Status.ServiceTokenID).To(Equal(tokenID))
+
+				stored := server.Secret("secret/cloudflare/token18")
+				g.Expect(stored).To(HaveKeyWithValue("serviceTokenID", tokenID))
+				g.Expect(stored).To(HaveKeyWithValue(token.Status.SecretRef.ClientSecretKey, clientSecret))
+				g.Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespaceName, &corev1.Secret{}))).To(BeTrue())
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			By("Refusing to switch back without access to the credentials in vault")
+			token.Spec.SecretStore = nil
+			Expect(k8sClient.Update(ctx, token)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, typeNamespaceName, token)).To(Succeed())
+				condition := meta.FindStatusCondition(token.Status.Conditions, statusDegrated)
+				g.Expect(condition).ToNot(BeNil())
+				g.Expect(condition.Reason).To(Equal(reasonSecretStoreChanged))
+				g.Expect(token.Status.ServiceTokenID).To(Equal(tokenID))
+			}, time.Second*10, time.Second).Should(Succeed())
+
+			Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, vaultToken)).To(Succeed())
+		})
 	})
 })
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// serviceTokenMetrics exposes the expiry and last use of the service tokens; the values are computed when scraped
+// so that they don't go stale between reconciliations.
+type serviceTokenMetrics struct {
+	mu         sync.Mutex
+	expiresAt  map[types.NamespacedName]time.Time
+	lastSeenAt map[types.NamespacedName]time.Time
+
+	expiryDesc   *prometheus.Desc
+	lastSeenDesc *prometheus.Desc
+}
+
+var serviceTokenMetricsCollector = &serviceTokenMetrics{
+	expiresAt:  map[types.NamespacedName]time.Time{},
+	lastSeenAt: map[types.NamespacedName]time.Time{},
+	expiryDesc: prometheus.NewDesc(
+		"cloudflare_service_token_expiry_seconds",
+		"Seconds until the service token expires; negative once expired",
+		[]string{"namespace", "name"}, nil,
+	),
+	lastSeenDesc: prometheus.NewDesc(
+		"cloudflare_service_token_last_seen_seconds",
+		"Seconds since the service token was last used",
+		[]string{"namespace", "name"}, nil,
+	),
+}
+
+func init() {
+	metrics.Registry.MustRegister(serviceTokenMetricsCollector)
+}
+
+// Set records the expiry and last use of a token; zero times are not exported.
+func (m *serviceTokenMetrics) Set(token types.NamespacedName, expiresAt time.Time, lastSeenAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.expiresAt, token)
+	delete(m.lastSeenAt, token)
+
+	if !expiresAt.IsZero() {
+		m.expiresAt[token] = expiresAt
+	}
+
+	if !lastSeenAt.IsZero() {
+		m.lastSeenAt[token] = lastSeenAt
+	}
+}
+
+// Delete removes the metrics of a deleted token.
+func (m *serviceTokenMetrics) Delete(token types.NamespacedName) {
+	m.Set(token, time.Time{}, time.Time{})
+}
+
+func (m *serviceTokenMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.expiryDesc
+	ch <- m.lastSeenDesc
+}
+
+func (m *serviceTokenMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, expiresAt := range m.expiresAt {
+		ch <- prometheus.MustNewConstMetric(m.expiryDesc, prometheus.GaugeValue, time.Until(expiresAt).Seconds(), token.Namespace, token.Name)
+	}
+
+	for token, lastSeenAt := range m.lastSeenAt {
+		ch <- prometheus.MustNewConstMetric(m.lastSeenDesc, prometheus.GaugeValue, time.Since(lastSeenAt).Seconds(), token.Namespace, token.Name)
+	}
+}
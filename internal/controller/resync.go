@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/config"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/ctrlhelper"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logger "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// resyncJitter spreads the resyncs of resources reconciled at the same time, ex: on startup, by up to 10%.
+const resyncJitter = 0.1
+
+var driftTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cloudflare_resource_drift_total",
+	Help: "Number of times a resource in cloudflare was updated although its desired state didn't change since it was last reconciled",
+}, []string{"kind"})
+
+func init() {
+	metrics.Registry.MustRegister(driftTotal)
+}
+
+// appliedStates stores the desired state applied by the last successful reconciliation of every resource, by kind and
+// namespaced name; an update in cloudflare while the desired state is unchanged reverts a change made in cloudflare.
+var appliedStates sync.Map
+
+type syncedKey struct {
+	kind string
+	types.NamespacedName
+}
+
+// desiredState hashes the desired state of a resource in cloudflare once its references, ex: to ConfigMaps or other
+// resources, are resolved.
+func desiredState(desired ...any) string {
+	data, _ := json.Marshal(desired) //nolint:errchkjson
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// resyncResult stores the desired state applied to the resource and requeues it after its resync interval with
+// jitter. An invalid interval is reported and disables the resync until it's fixed.
+func resyncResult(ctx context.Context, obj ctrlhelper.CloudflareCR, desired string) ctrl.Result {
+	appliedStates.Store(syncedKey{obj.GetType(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}}, desired)
+
+	interval, err := config.ResyncInterval(obj)
+	if err != nil {
+		logger.FromContext(ctx).Info("resync disabled", "error", err.Error())
+
+		return ctrl.Result{}
+	}
+
+	if interval == 0 {
+		return ctrl.Result{}
+	}
+
+	return ctrl.Result{RequeueAfter: wait.Jitter(interval, resyncJitter)}
+}
+
+// earliest returns the result requeuing first; results without requeue are ignored.
+func earliest(results ...ctrl.Result) ctrl.Result {
+	result := ctrl.Result{}
+	for _, r := range results {
+		if r.RequeueAfter > 0 && (result.RequeueAfter == 0 || r.RequeueAfter < result.RequeueAfter) {
+			result = r
+		}
+	}
+
+	return result
+}
+
+// recordDrift counts the updates in cloudflare of a reconciliation as drift if the desired state is the one applied
+// by the last successful reconciliation; changes of the resource or of what it references aren't drift. It's called
+// at most once per reconciliation.
+func recordDrift(obj ctrlhelper.CloudflareCR, desired string) {
+	applied, ok := appliedStates.Load(syncedKey{obj.GetType(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}})
+	if ok && applied == desired {
+		driftTotal.WithLabelValues(obj.GetType()).Inc()
+	}
+}
+
+// forgetSynced removes a deleted resource from the applied states.
+func forgetSynced(kind string, name types.NamespacedName) {
+	appliedStates.Delete(syncedKey{kind, name})
+}
@@ -113,8 +113,9 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).ToNot(HaveOccurred())
 
+	// dependents are looked up with the field indexes of the manager's cache
 	controllerHelper := &ctrlhelper.ControllerHelper{
-		R: k8sClient,
+		R: k8sManager.GetClient(),
 	}
 
 	Expect((&CloudflareAccessGroupReconciler{
@@ -128,6 +129,17 @@ var _ = BeforeSuite(func() {
 		Helper: controllerHelper,
 	}).SetupWithManager(k8sManager)).ToNot(HaveOccurred())
 	Expect((&CloudflareServiceTokenReconciler{
+		Client:   k8sClient,
+		Scheme:   k8sClient.Scheme(),
+		Helper:   controllerHelper,
+		Recorder: k8sManager.GetEventRecorderFor("cloudflareservicetoken-controller"),
+	}).SetupWithManager(k8sManager)).ToNot(HaveOccurred())
+	Expect((&CloudflareDevicePostureRuleReconciler{
+		Client: k8sClient,
+		Scheme: k8sClient.Scheme(),
+		Helper: controllerHelper,
+	}).SetupWithManager(k8sManager)).ToNot(HaveOccurred())
+	Expect((&CloudflareListReconciler{
 		Client: k8sClient,
 		Scheme: k8sClient.Scheme(),
 		Helper: controllerHelper,
@@ -0,0 +1,448 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+
+	v1alpha1 "github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/ctrlhelper"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/services"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logger "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// requestsForReferenceGrant returns a request for every resource of the given kind in the namespaces trusted by the grant,
+// so that references rejected earlier are re-evaluated once a grant is created, changed or removed.
+func requestsForReferenceGrant(ctx context.Context, c client.Client, obj client.Object, kind string, list client.ObjectList) []reconcile.Request {
+	grant, ok := obj.(*v1alpha1.CloudflareReferenceGrant)
+	if !ok {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	seen := map[string]bool{}
+
+	for _, from := range grant.Spec.From {
+		if from.Kind != kind || seen[from.Namespace] {
+			continue
+		}
+		seen[from.Namespace] = true
+
+		if err := c.List(ctx, list, client.InNamespace(from.Namespace)); err != nil {
+			logger.FromContext(ctx).Error(err, "unable to list resources for reference grant", "kind", kind, "namespace", from.Namespace)
+
+			continue
+		}
+
+		_ = meta.EachListItem(list, func(item runtime.Object) error {
+			if o, ok := item.(client.Object); ok {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}})
+			}
+
+			return nil
+		})
+	}
+
+	return requests
+}
+
+// nodeExternalIPsIndex is the field index of the resources reading the external IPs of nodes.
+const nodeExternalIPsIndex = ".spec.references.nodeExternalIPs"
+
+// setupReferenceIndexes indexes obj by the namespaced names of the resources it references with valueFrom, and
+// by whether it reads the external IPs of nodes.
+func setupReferenceIndexes(ctx context.Context, mgr ctrl.Manager, obj client.Object, policies func(client.Object) []services.AccessPolicyList) error {
+	for kind, index := range services.ReferenceIndexes {
+		kind := kind
+		if err := mgr.GetFieldIndexer().IndexField(ctx, obj, index, func(o client.Object) []string {
+			return services.ReferencedResources(policies(o), kind)
+		}); err != nil {
+			return errors.Wrapf(err, "unable to index %s references", kind)
+		}
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, obj, nodeExternalIPsIndex, func(o client.Object) []string {
+		if services.UsesNodeExternalIPs(policies(o)) {
+			return []string{"true"}
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "unable to index node external IPs references")
+	}
+
+	return nil
+}
+
+func accessGroupPolicies(obj client.Object) []services.AccessPolicyList {
+	group, ok := obj.(*v1alpha1.CloudflareAccessGroup)
+	if !ok {
+		return nil
+	}
+
+	return []services.AccessPolicyList{group.Spec}
+}
+
+func accessApplicationPolicies(obj client.Object) []services.AccessPolicyList {
+	app, ok := obj.(*v1alpha1.CloudflareAccessApplication)
+	if !ok {
+		return nil
+	}
+
+	return services.ToAccessPolicyList(app.Spec.Policies)
+}
+
+// referencedResourcePredicate only passes the events of a referenced resource that can change the references
+// resolved by its dependents; creation, deletion, a change of its cloudflare ID or of its labels.
+func referencedResourcePredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldCR, oldOk := e.ObjectOld.(ctrlhelper.CloudflareCR)
+			newCR, newOk := e.ObjectNew.(ctrlhelper.CloudflareCR)
+			if !oldOk || !newOk {
+				return true
+			}
+
+			// the previous token of an overlapping rotation is allowed by policies until the end of the window
+			oldToken, oldIsToken := e.ObjectOld.(*v1alpha1.CloudflareServiceToken)
+			newToken, newIsToken := e.ObjectNew.(*v1alpha1.CloudflareServiceToken)
+			if oldIsToken && newIsToken && oldToken.Status.PreviousServiceTokenID != newToken.Status.PreviousServiceTokenID {
+				return true
+			}
+
+			return oldCR.GetID() != newCR.GetID() || !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+		},
+	}
+}
+
+// dependentsHandler enqueues the dependents of a referenced resource of the given kind.
+func dependentsHandler(c client.Client, kind string, dependents func(context.Context, client.Client, string, client.Object) []reconcile.Request) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		return dependents(ctx, c, kind, obj)
+	})
+}
+
+// requestsForDependentAccessGroups returns a request for every CloudflareAccessGroup referencing obj,
+// either by name or with a selector matching it.
+func requestsForDependentAccessGroups(ctx context.Context, c client.Client, kind string, obj client.Object) []reconcile.Request {
+	requests := []reconcile.Request{}
+
+	groups := &v1alpha1.CloudflareAccessGroupList{}
+	if err := c.List(ctx, groups, client.MatchingFields{services.ReferenceIndexes[kind]: client.ObjectKeyFromObject(obj).String()}); err != nil {
+		logger.FromContext(ctx).Error(err, "unable to list referencing CloudflareAccessGroups")
+	}
+
+	for _, group := range groups.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: group.Namespace, Name: group.Name}})
+	}
+
+	return append(requests, requestsForSelectingAccessGroups(ctx, c, kind, obj)...)
+}
+
+// requestsForDependentAccessApplications returns a request for every CloudflareAccessApplication referencing obj,
+// either by name or with a selector matching it.
+func requestsForDependentAccessApplications(ctx context.Context, c client.Client, kind string, obj client.Object) []reconcile.Request {
+	requests := []reconcile.Request{}
+
+	apps := &v1alpha1.CloudflareAccessApplicationList{}
+	if err := c.List(ctx, apps, client.MatchingFields{services.ReferenceIndexes[kind]: client.ObjectKeyFromObject(obj).String()}); err != nil {
+		logger.FromContext(ctx).Error(err, "unable to list referencing CloudflareAccessApplications")
+	}
+
+	for _, app := range apps.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: app.Namespace, Name: app.Name}})
+	}
+
+	return append(requests, requestsForSelectingAccessApplications(ctx, c, kind, obj)...)
+}
+
+// requestsForSelectingAccessGroups returns a request for every CloudflareAccessGroup with a selector matching obj,
+// so that groups pick up selected resources when they appear, change or disappear.
+func requestsForSelectingAccessGroups(ctx context.Context, c client.Client, kind string, obj client.Object) []reconcile.Request {
+	if kind != "CloudflareAccessGroup" && kind != "CloudflareServiceToken" {
+		return nil
+	}
+
+	groups := &v1alpha1.CloudflareAccessGroupList{}
+	if err := c.List(ctx, groups); err != nil {
+		logger.FromContext(ctx).Error(err, "unable to list CloudflareAccessGroups")
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, group := range groups.Items {
+		if services.SelectsResource([]services.AccessPolicyList{group.Spec}, group.Namespace, kind, obj) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: group.Namespace, Name: group.Name}})
+		}
+	}
+
+	return requests
+}
+
+// requestsForSelectingAccessApplications returns a request for every CloudflareAccessApplication with a selector matching obj,
+// so that applications pick up selected resources when they appear, change or disappear.
+func requestsForSelectingAccessApplications(ctx context.Context, c client.Client, kind string, obj client.Object) []reconcile.Request {
+	if kind != "CloudflareAccessGroup" && kind != "CloudflareServiceToken" {
+		return nil
+	}
+
+	apps := &v1alpha1.CloudflareAccessApplicationList{}
+	if err := c.List(ctx, apps); err != nil {
+		logger.FromContext(ctx).Error(err, "unable to list CloudflareAccessApplications")
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, app := range apps.Items {
+		if services.SelectsResource(services.ToAccessPolicyList(app.Spec.Policies), app.Namespace, kind, obj) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: app.Namespace, Name: app.Name}})
+		}
+	}
+
+	return requests
+}
+
+// referrerPolicies returns the policies of a CloudflareAccessGroup or CloudflareAccessApplication.
+func referrerPolicies(obj client.Object) []services.AccessPolicyList {
+	if _, ok := obj.(*v1alpha1.CloudflareAccessGroup); ok {
+		return accessGroupPolicies(obj)
+	}
+
+	return accessApplicationPolicies(obj)
+}
+
+// requestsForReferencedResources returns a request for every resource of the given kind referenced by the
+// CloudflareAccessGroup or CloudflareAccessApplication obj, so that the dependents in their status are kept up to date.
+func requestsForReferencedResources(ctx context.Context, c client.Client, kind string, obj client.Object, list client.ObjectList) []reconcile.Request {
+	policies := referrerPolicies(obj)
+	requests := []reconcile.Request{}
+
+	for _, ref := range services.ReferencedResources(policies, kind) {
+		namespace, name, _ := strings.Cut(ref, "/")
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}})
+	}
+
+	if len(services.Selectors(policies, kind)) == 0 {
+		return requests
+	}
+
+	if err := c.List(ctx, list); err != nil {
+		logger.FromContext(ctx).Error(err, "unable to list selected resources", "kind", kind)
+
+		return requests
+	}
+
+	_ = meta.EachListItem(list, func(item runtime.Object) error {
+		if o, ok := item.(client.Object); ok && services.SelectsResource(policies, obj.GetNamespace(), kind, o) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}})
+		}
+
+		return nil
+	})
+
+	return requests
+}
+
+// referrersHandler enqueues the resources of the given kind referenced by an access group or application. On updates
+// only the resources which started or stopped being referenced are enqueued, as the others keep their dependents.
+func referrersHandler(c client.Client, kind string, list func() client.ObjectList) handler.EventHandler {
+	enqueue := func(ctx context.Context, obj client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		for _, request := range requestsForReferencedResources(ctx, c, kind, obj, list()) {
+			q.Add(request)
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, q)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			oldRequests := requestsForReferencedResources(ctx, c, kind, e.ObjectOld, list())
+			newRequests := requestsForReferencedResources(ctx, c, kind, e.ObjectNew, list())
+
+			for _, request := range oldRequests {
+				if !slices.Contains(newRequests, request) {
+					q.Add(request)
+				}
+			}
+
+			for _, request := range newRequests {
+				if !slices.Contains(oldRequests, request) {
+					q.Add(request)
+				}
+			}
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, q)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, q)
+		},
+	}
+}
+
+// requestsForCyclicAccessGroups returns a request for every CloudflareAccessGroup reporting a reference cycle,
+// so that the condition is re-evaluated whenever a group changes and possibly breaks the cycle.
+func requestsForCyclicAccessGroups(ctx context.Context, c client.Client) []reconcile.Request {
+	groups := &v1alpha1.CloudflareAccessGroupList{}
+	if err := c.List(ctx, groups); err != nil {
+		logger.FromContext(ctx).Error(err, "unable to list CloudflareAccessGroups")
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, group := range groups.Items {
+		if condition := meta.FindStatusCondition(group.Status.Conditions, statusDegrated); condition != nil && condition.Reason == reasonReferenceCycle {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: group.Namespace, Name: group.Name}})
+		}
+	}
+
+	return requests
+}
+
+// nodeAddressesPredicate only passes the node events that can change the external IPs selected by access rules.
+func nodeAddressesPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldNode, oldOk := e.ObjectOld.(*corev1.Node)
+			newNode, newOk := e.ObjectNew.(*corev1.Node)
+			if !oldOk || !newOk {
+				return true
+			}
+
+			return !reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses) || !reflect.DeepEqual(oldNode.Labels, newNode.Labels)
+		},
+	}
+}
+
+// serviceLoadBalancerPredicate only passes the Service events that can change the resolved references; creation,
+// deletion or a change of the load balancer ingress IPs.
+func serviceLoadBalancerPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldService, oldOk := e.ObjectOld.(*corev1.Service)
+			newService, newOk := e.ObjectNew.(*corev1.Service)
+			if !oldOk || !newOk {
+				return true
+			}
+
+			return !reflect.DeepEqual(oldService.Status.LoadBalancer.Ingress, newService.Status.LoadBalancer.Ingress)
+		},
+	}
+}
+
+// requestsForNodeExternalIPs returns a request for every resource in list whose rules read the external IPs of nodes
+// with a selector matching the node. Both the old and the new node of an update are mapped, so resources are also
+// reconciled when a node stops matching.
+func requestsForNodeExternalIPs(ctx context.Context, c client.Client, node client.Object, list client.ObjectList, policies func(client.Object) []services.AccessPolicyList) []reconcile.Request {
+	if err := c.List(ctx, list, client.MatchingFields{nodeExternalIPsIndex: "true"}); err != nil {
+		logger.FromContext(ctx).Error(err, "unable to list resources reading node external IPs")
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	_ = meta.EachListItem(list, func(item runtime.Object) error {
+		if o, ok := item.(client.Object); ok && services.SelectsNode(policies(o), node) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}})
+		}
+
+		return nil
+	})
+
+	return requests
+}
+
+// requestsForSecretReplica returns a request for the service token a secret was replicated from.
+func requestsForSecretReplica(_ context.Context, obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[v1alpha1.LabelReplicaOf]
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Namespace: obj.GetLabels()[v1alpha1.LabelReplicaOfNamespace],
+		Name:      name,
+	}}}
+}
+
+// requestsForReplicaNamespace returns a request for every service token replicating into the namespace by name
+// or with a selector; the replicas are removed when the namespace stops matching.
+func requestsForReplicaNamespace(c client.Client) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		log := logger.FromContext(ctx)
+
+		tokens := &v1alpha1.CloudflareServiceTokenList{}
+		if err := c.List(ctx, tokens); err != nil {
+			log.Error(err, "unable to list service tokens")
+
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, token := range tokens.Items {
+			if token.Spec.Replication == nil {
+				continue
+			}
+
+			if token.Spec.Replication.NamespaceSelector != nil || slices.Contains(token.Spec.Replication.Namespaces, obj.GetName()) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: token.Namespace, Name: token.Name}})
+			}
+		}
+
+		return requests
+	}
+}
+
+// serviceTokenBindingsPredicate passes the service token events changing the applications bound by the token.
+func serviceTokenBindingsPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldToken, oldOk := e.ObjectOld.(*v1alpha1.CloudflareServiceToken)
+			newToken, newOk := e.ObjectNew.(*v1alpha1.CloudflareServiceToken)
+			if !oldOk || !newOk {
+				return true
+			}
+
+			return !reflect.DeepEqual(oldToken.Spec.Applications, newToken.Spec.Applications) || oldToken.UnderDeletion() != newToken.UnderDeletion()
+		},
+	}
+}
+
+// requestsForBoundAccessApplications returns a request for every CloudflareAccessApplication listed in the
+// spec.applications of a service token. Both the old and the new token of an update are mapped, so applications
+// removed from the list drop the token from their managed policy.
+func requestsForBoundAccessApplications(_ context.Context, obj client.Object) []reconcile.Request {
+	token, ok := obj.(*v1alpha1.CloudflareServiceToken)
+	if !ok {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, app := range token.Spec.Applications {
+		namespace := app.Namespace
+		if namespace == "" {
+			namespace = token.Namespace
+		}
+
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: app.Name}})
+	}
+
+	return requests
+}
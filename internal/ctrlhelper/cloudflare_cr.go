@@ -1,6 +1,9 @@
 package ctrlhelper
 
-import "sigs.k8s.io/controller-runtime/pkg/client"
+import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
 
 type CloudflareCR interface {
 	GetID() string
@@ -8,3 +11,10 @@ type CloudflareCR interface {
 	UnderDeletion() bool
 	client.Object
 }
+
+// CloudflareReferencedCR is a CloudflareCR that can be referenced by access groups and applications.
+type CloudflareReferencedCR interface {
+	GetDependents() []v1alpha1.ResourceReference
+	SetDependents(dependents []v1alpha1.ResourceReference)
+	CloudflareCR
+}
@@ -6,8 +6,11 @@ import (
 
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cfapi"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/secretsinks"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/services"
 	"github.com/cloudflare/cloudflare-go"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	logger "sigs.k8s.io/controller-runtime/pkg/log"
@@ -65,18 +68,47 @@ func (h *ControllerHelper) ReconcileDeletion(ctx context.Context, api *cfapi.API
 	// The object is being deleted
 	//nolint:nestif
 	if controllerutil.ContainsFinalizer(k8sCR, v1alpha1.FinalizerDeletion) {
+		blocked, err := h.blockedByDependents(ctx, k8sCR)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to reconcile dependents")
+		}
+
+		if blocked {
+			log.Info("deletion is blocked while the resource is still referenced", "annotation", v1alpha1.AnnotationBlockDeletionWithDependents)
+
+			return false, nil
+		}
+
 		// our finalizer is present, so lets handle any external dependency
 		if k8sCR.GetID() != "" {
 			log.Info("will remove resource in Cloudflare")
 			var err error
 
-			switch k8sCR.(type) {
+			switch cr := k8sCR.(type) {
 			case *v1alpha1.CloudflareAccessApplication:
 				err = api.DeleteAccessApplication(ctx, k8sCR.GetID())
 			case *v1alpha1.CloudflareAccessGroup:
 				err = api.DeleteAccessGroup(ctx, k8sCR.GetID())
+				// shards can only be removed once the parent group doesn't include them anymore
+				var notFound *cloudflare.NotFoundError
+				if err == nil || errors.As(err, &notFound) {
+					if shardErr := deleteAccessGroupShards(ctx, api, cr.Status.Shards); shardErr != nil {
+						err = shardErr
+					}
+				}
 			case *v1alpha1.CloudflareServiceToken:
 				err = api.DeleteAccessServiceToken(ctx, k8sCR.GetID())
+				// the previous token of an overlapping rotation is removed with the token
+				var notFound *cloudflare.NotFoundError
+				if previousID := cr.Status.PreviousServiceTokenID; previousID != "" && (err == nil || errors.As(err, &notFound)) {
+					if previousErr := api.DeleteAccessServiceToken(ctx, previousID); previousErr != nil && !errors.As(previousErr, &notFound) {
+						err = previousErr
+					}
+				}
+			case *v1alpha1.CloudflareDevicePostureRule:
+				err = api.DeleteDevicePostureRule(ctx, k8sCR.GetID())
+			case *v1alpha1.CloudflareList:
+				err = api.DeleteTeamsList(ctx, k8sCR.GetID())
 			default:
 				return false, errors.Errorf("unknown type %T", k8sCR)
 			}
@@ -95,6 +127,25 @@ func (h *ControllerHelper) ReconcileDeletion(ctx context.Context, api *cfapi.API
 			}
 		}
 
+		// shards are stored before the parent group is created; they are removed even if the parent never was
+		if group, ok := k8sCR.(*v1alpha1.CloudflareAccessGroup); ok && group.GetID() == "" {
+			if err := deleteAccessGroupShards(ctx, api, group.Status.Shards); err != nil {
+				log.Error(err, "unable to delete")
+
+				return false, errors.Wrap(err, "unable to delete")
+			}
+		}
+
+		if token, ok := k8sCR.(*v1alpha1.CloudflareServiceToken); ok {
+			if err := h.deleteSecretReplicas(ctx, token); err != nil {
+				return false, err
+			}
+
+			if err := h.deleteStoredCredentials(ctx, token); err != nil {
+				return false, err
+			}
+		}
+
 		// remove our finalizer from the list and update it.
 		controllerutil.RemoveFinalizer(k8sCR, v1alpha1.FinalizerDeletion)
 		if err := h.R.Update(ctx, k8sCR); err != nil {
@@ -109,3 +160,81 @@ func (h *ControllerHelper) ReconcileDeletion(ctx context.Context, api *cfapi.API
 
 	return false, nil
 }
+
+// ReconcileDependents stores the access groups and applications currently referencing the CR in its status.
+func (h *ControllerHelper) ReconcileDependents(ctx context.Context, k8sCR CloudflareReferencedCR) error {
+	dependents, err := services.FindDependents(ctx, h.R, k8sCR.GetType(), k8sCR)
+	if err != nil {
+		return errors.Wrap(err, "unable to find dependents")
+	}
+
+	if _, err := controllerutil.CreateOrPatch(ctx, h.R, k8sCR, func() error {
+		k8sCR.SetDependents(dependents)
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "unable to update dependents")
+	}
+
+	return nil
+}
+
+// blockedByDependents returns true if the CR opted into blocking its deletion and is still referenced.
+func (h *ControllerHelper) blockedByDependents(ctx context.Context, k8sCR CloudflareCR) (bool, error) {
+	referenced, ok := k8sCR.(CloudflareReferencedCR)
+	if !ok {
+		return false, nil
+	}
+
+	if block, _ := strconv.ParseBool(k8sCR.GetAnnotations()[v1alpha1.AnnotationBlockDeletionWithDependents]); !block {
+		return false, nil
+	}
+
+	if err := h.ReconcileDependents(ctx, referenced); err != nil {
+		return false, err
+	}
+
+	return len(referenced.GetDependents()) > 0, nil
+}
+
+func deleteAccessGroupShards(ctx context.Context, api *cfapi.API, shards []v1alpha1.AccessGroupShard) error {
+	for _, shard := range shards {
+		if err := api.DeleteAccessGroup(ctx, shard.AccessGroupID); err != nil {
+			var notFound *cloudflare.NotFoundError
+			if !errors.As(err, &notFound) {
+				return errors.Wrapf(err, "unable to delete access group shard %s", shard.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteSecretReplicas removes the copies of the secret of the token in other namespaces.
+func (h *ControllerHelper) deleteSecretReplicas(ctx context.Context, token *v1alpha1.CloudflareServiceToken) error {
+	replicas := &corev1.SecretList{}
+	if err := h.R.List(ctx, replicas, client.MatchingLabels(token.ReplicaLabels())); err != nil {
+		return errors.Wrap(err, "unable to list secret replicas")
+	}
+
+	for i := range replicas.Items {
+		if err := h.R.Delete(ctx, &replicas.Items[i]); client.IgnoreNotFound(err) != nil {
+			return errors.Wrapf(err, "unable to delete secret replica in %s", replicas.Items[i].Namespace)
+		}
+	}
+
+	return nil
+}
+
+// deleteStoredCredentials removes the credentials of the token from its secret sink. A sink that can't be
+// initialized, ex: because the Vault token was removed, doesn't block the deletion.
+func (h *ControllerHelper) deleteStoredCredentials(ctx context.Context, token *v1alpha1.CloudflareServiceToken) error {
+	sink, err := secretsinks.New(ctx, h.R, token)
+	if err != nil {
+		logger.FromContext(ctx).Info("unable to initialize secret sink - credentials are not removed", "error", err.Error())
+
+		return nil
+	}
+
+	return errors.Wrap(sink.Delete(ctx), "unable to delete stored credentials")
+}
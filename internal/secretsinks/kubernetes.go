@@ -0,0 +1,187 @@
+package secretsinks
+
+import (
+	"context"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/cftypes"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logger "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// KubernetesSink stores the credentials in a secret next to the service token, owned by the token.
+type KubernetesSink struct {
+	Client client.Client
+	Token  *v1alpha1.CloudflareServiceToken
+
+	// Secret holding the credentials, set by Read and Write
+	Secret *corev1.Secret
+}
+
+// find returns the secret created for the token, nil if there is none.
+func (k *KubernetesSink) find(ctx context.Context) (*corev1.Secret, error) {
+	secretList := &corev1.SecretList{}
+	if err := k.Client.List(ctx, secretList,
+		client.MatchingLabels{v1alpha1.LabelOwnedBy: k.Token.Name},
+		client.InNamespace(k.Token.Namespace),
+	); err != nil {
+		return nil, errors.Wrap(err, "unable to list created secrets")
+	}
+
+	if len(secretList.Items) == 0 {
+		return nil, nil
+	}
+
+	if len(secretList.Items) > 1 {
+		logger.FromContext(ctx).Info("Found multiple secrets with the same owner label", "label", v1alpha1.LabelOwnedBy, "owner", k.Token.Name)
+	}
+
+	return &secretList.Items[0], nil
+}
+
+func (k *KubernetesSink) Read(ctx context.Context) (*Credentials, error) {
+	secret, err := k.find(ctx)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+
+	k.Secret = secret
+
+	token := cftypes.ExtendedServiceToken{}
+	if err := token.SetSecretValues(*secret); err != nil {
+		return nil, errors.Wrap(err, "failed to read secret")
+	}
+
+	return &Credentials{
+		TokenID:              string(secret.Data[secret.Annotations[v1alpha1.AnnotationTokenIDKey]]),
+		ClientID:             token.ClientID,
+		ClientSecret:         token.ClientSecret,
+		PreviousTokenID:      string(secret.Data[v1alpha1.PreviousKey(secret.Annotations[v1alpha1.AnnotationTokenIDKey])]),
+		PreviousClientID:     string(secret.Data[v1alpha1.PreviousKey(secret.Annotations[v1alpha1.AnnotationClientIDKey])]),
+		PreviousClientSecret: string(secret.Data[v1alpha1.PreviousKey(secret.Annotations[v1alpha1.AnnotationClientSecretKey])]),
+	}, nil
+}
+
+// Write creates or updates the secret named after the template; a secret that was renamed is removed.
+func (k *KubernetesSink) Write(ctx context.Context, credentials Credentials) (v1alpha1.SecretRef, error) {
+	log := logger.FromContext(ctx)
+	template := k.Token.Spec.Template
+
+	previous := k.Secret
+	if previous == nil {
+		var err error
+		if previous, err = k.find(ctx); err != nil {
+			return v1alpha1.SecretRef{}, err
+		}
+	}
+
+	name := k.Token.Name
+	if template.Name != "" {
+		name = template.Name
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: k.Token.Namespace,
+		},
+	}
+
+	secretAnnotations := map[string]string{
+		v1alpha1.AnnotationClientIDKey:     template.ClientIDKey,
+		v1alpha1.AnnotationClientSecretKey: template.ClientSecretKey,
+		v1alpha1.AnnotationTokenIDKey:      v1alpha1.SecretKeyTokenID,
+	}
+
+	for annotationKey, annotationValue := range template.Annotations {
+		if _, exists := secretAnnotations[annotationKey]; !exists {
+			secretAnnotations[annotationKey] = annotationValue
+		}
+	}
+
+	secretLabels := map[string]string{
+		v1alpha1.LabelOwnedBy: k.Token.Name,
+	}
+
+	for labelKey, labelValue := range template.Labels {
+		if _, exists := secretLabels[labelKey]; !exists {
+			secretLabels[labelKey] = labelValue
+		}
+	}
+
+	// the client id, secret and token id are written in a single update; conflicts are retried so that
+	// renewed credentials aren't lost
+	var op controllerutil.OperationResult
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		op, err = controllerutil.CreateOrUpdate(ctx, k.Client, secret, func() error {
+			secret.SetLabels(secretLabels)
+			secret.SetAnnotations(secretAnnotations)
+
+			secret.Data = map[string][]byte{}
+			for key, value := range credentials.Data {
+				secret.Data[key] = value
+			}
+			secret.Data[template.ClientSecretKey] = []byte(credentials.ClientSecret)
+			secret.Data[template.ClientIDKey] = []byte(credentials.ClientID)
+			secret.Data[v1alpha1.SecretKeyTokenID] = []byte(credentials.TokenID)
+
+			if credentials.PreviousTokenID != "" {
+				secret.Data[v1alpha1.PreviousKey(template.ClientSecretKey)] = []byte(credentials.PreviousClientSecret)
+				secret.Data[v1alpha1.PreviousKey(template.ClientIDKey)] = []byte(credentials.PreviousClientID)
+				secret.Data[v1alpha1.PreviousKey(v1alpha1.SecretKeyTokenID)] = []byte(credentials.PreviousTokenID)
+			}
+
+			if err := ctrl.SetControllerReference(k.Token, secret, k.Client.Scheme()); err != nil {
+				return errors.Wrap(err, "unable to set secret owner reference")
+			}
+
+			return nil
+		})
+
+		//nolint:wrapcheck
+		return err
+	})
+	if err != nil {
+		return v1alpha1.SecretRef{}, errors.Wrap(err, "Failed to create/update Secret")
+	}
+
+	if op == controllerutil.OperationResultCreated {
+		log.Info("created secret")
+	} else if op == controllerutil.OperationResultUpdated {
+		log.Info("updated secret")
+	}
+
+	// secret exists & was renamed; remove the old one
+	if previous != nil && previous.Name != secret.Name {
+		if err := k.Client.Delete(ctx, previous); err != nil {
+			log.Error(nil, "failed to remove old secret")
+		} else {
+			log.Info("removed old secret")
+		}
+	}
+
+	k.Secret = secret
+
+	return v1alpha1.SecretRef{
+		LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+		ClientSecretKey:      template.ClientSecretKey,
+		ClientIDKey:          template.ClientIDKey,
+		Sink:                 SinkKubernetes,
+	}, nil
+}
+
+func (k *KubernetesSink) Delete(ctx context.Context) error {
+	secret, err := k.find(ctx)
+	if err != nil || secret == nil {
+		return err
+	}
+
+	return errors.Wrap(client.IgnoreNotFound(k.Client.Delete(ctx, secret)), "unable to delete secret")
+}
@@ -0,0 +1,93 @@
+package secretsinks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Names of the sinks reported in status.secretRef.sink.
+const (
+	SinkKubernetes = "Kubernetes"
+	SinkVault      = "Vault"
+)
+
+// Credentials are the values of a service token stored in a sink.
+type Credentials struct {
+	TokenID      string
+	ClientID     string
+	ClientSecret string
+	// Data holds the rendered template entries
+	Data map[string][]byte
+
+	// Credentials of the previous token during the overlap window of a rotation
+	PreviousTokenID      string
+	PreviousClientID     string
+	PreviousClientSecret string
+}
+
+// Hash returns a hash of the current credentials, which changes when the token is renewed or recreated.
+func (c Credentials) Hash() string {
+	sum := sha256.Sum256([]byte(c.TokenID + "\n" + c.ClientID + "\n" + c.ClientSecret))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Sink stores the credentials of a service token.
+type Sink interface {
+	// Read returns the stored credentials or nil if the sink doesn't hold any.
+	Read(ctx context.Context) (*Credentials, error)
+	// Write stores the credentials and returns a reference to them.
+	Write(ctx context.Context, credentials Credentials) (v1alpha1.SecretRef, error)
+	// Delete removes the stored credentials.
+	Delete(ctx context.Context) error
+}
+
+// New returns the sink configured for the service token; a kubernetes secret by default.
+func New(ctx context.Context, c client.Client, token *v1alpha1.CloudflareServiceToken) (Sink, error) {
+	if token.Spec.SecretStore != nil && token.Spec.SecretStore.Vault != nil {
+		return NewVaultSink(ctx, c, token)
+	}
+
+	return &KubernetesSink{Client: c, Token: token}, nil
+}
+
+// Previous returns the sink holding the credentials according to the status of the token if the secret store changed
+// since, nil otherwise. Credentials in vault can only be read with the vault token of the current spec.
+func Previous(ctx context.Context, c client.Client, token *v1alpha1.CloudflareServiceToken) (Sink, error) {
+	ref := token.Status.SecretRef
+	if ref == nil {
+		return nil, nil
+	}
+
+	vault := token.Spec.SecretStore != nil && token.Spec.SecretStore.Vault != nil
+
+	if ref.Sink != SinkVault || ref.Vault == nil {
+		if !vault {
+			return nil, nil
+		}
+
+		return &KubernetesSink{Client: c, Token: token}, nil
+	}
+
+	if !vault {
+		return nil, errors.Errorf("the credentials stored in vault at %s/%s can't be read without a vault token", ref.Vault.Mount, ref.Vault.Path)
+	}
+
+	sink, err := NewVaultSink(ctx, c, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if sink.Address == ref.Vault.Address && sink.Mount == ref.Vault.Mount && sink.Path == ref.Vault.Path {
+		return nil, nil
+	}
+
+	sink.Address, sink.Mount, sink.Path = ref.Vault.Address, ref.Vault.Mount, ref.Vault.Path
+
+	return sink, nil
+}
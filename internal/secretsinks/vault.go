@@ -0,0 +1,201 @@
+package secretsinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// vaultTimeout bounds the requests to vault so that an unresponsive vault doesn't block the reconciliation.
+const vaultTimeout = 30 * time.Second
+
+// VaultSink stores the credentials in a HashiCorp Vault KV version 2 secrets engine.
+type VaultSink struct {
+	Address string
+	Mount   string
+	Path    string
+	Token   string
+
+	ClientIDKey     string
+	ClientSecretKey string
+
+	HTTPClient *http.Client
+}
+
+// NewVaultSink returns the Vault sink of the service token, authenticated with the token of the referenced secret.
+func NewVaultSink(ctx context.Context, c client.Client, token *v1alpha1.CloudflareServiceToken) (*VaultSink, error) {
+	spec := token.Spec.SecretStore.Vault
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: token.Namespace, Name: spec.TokenSecretRef.Name}, secret); err != nil {
+		return nil, errors.Wrap(err, "unable to get vault token secret")
+	}
+
+	vaultToken, ok := secret.Data[spec.TokenSecretRef.Key]
+	if !ok {
+		return nil, errors.Errorf("missing key %s in vault token secret %s", spec.TokenSecretRef.Key, spec.TokenSecretRef.Name)
+	}
+
+	mount := spec.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+
+	return &VaultSink{
+		Address:         spec.Address,
+		Mount:           mount,
+		Path:            spec.Path,
+		Token:           strings.TrimSpace(string(vaultToken)),
+		ClientIDKey:     token.Spec.Template.ClientIDKey,
+		ClientSecretKey: token.Spec.Template.ClientSecretKey,
+		HTTPClient:      &http.Client{Timeout: vaultTimeout},
+	}, nil
+}
+
+func (v *VaultSink) Read(ctx context.Context) (*Credentials, error) {
+	data, found, err := v.read(ctx)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	credentials := &Credentials{Data: map[string][]byte{}}
+	for key, value := range data {
+		switch key {
+		case v1alpha1.SecretKeyTokenID:
+			credentials.TokenID = value
+		case v.ClientIDKey:
+			credentials.ClientID = value
+		case v.ClientSecretKey:
+			credentials.ClientSecret = value
+		case v1alpha1.PreviousKey(v1alpha1.SecretKeyTokenID):
+			credentials.PreviousTokenID = value
+		case v1alpha1.PreviousKey(v.ClientIDKey):
+			credentials.PreviousClientID = value
+		case v1alpha1.PreviousKey(v.ClientSecretKey):
+			credentials.PreviousClientSecret = value
+		default:
+			credentials.Data[key] = []byte(value)
+		}
+	}
+
+	return credentials, nil
+}
+
+func (v *VaultSink) Write(ctx context.Context, credentials Credentials) (v1alpha1.SecretRef, error) {
+	data := map[string]string{}
+	for key, value := range credentials.Data {
+		data[key] = string(value)
+	}
+	data[v1alpha1.SecretKeyTokenID] = credentials.TokenID
+	data[v.ClientIDKey] = credentials.ClientID
+	data[v.ClientSecretKey] = credentials.ClientSecret
+
+	if credentials.PreviousTokenID != "" {
+		data[v1alpha1.PreviousKey(v1alpha1.SecretKeyTokenID)] = credentials.PreviousTokenID
+		data[v1alpha1.PreviousKey(v.ClientIDKey)] = credentials.PreviousClientID
+		data[v1alpha1.PreviousKey(v.ClientSecretKey)] = credentials.PreviousClientSecret
+	}
+
+	// every write creates a new version of the secret; unchanged credentials aren't written again so that the
+	// versions kept by vault aren't pushed out by the periodic reconciliations
+	current, found, err := v.read(ctx)
+	if err != nil {
+		return v1alpha1.SecretRef{}, err
+	}
+
+	if !found || !maps.Equal(current, data) {
+		if _, err := v.do(ctx, http.MethodPost, "data", map[string]interface{}{"data": data}, nil); err != nil {
+			return v1alpha1.SecretRef{}, err
+		}
+	}
+
+	return v1alpha1.SecretRef{
+		ClientSecretKey: v.ClientSecretKey,
+		ClientIDKey:     v.ClientIDKey,
+		Sink:            SinkVault,
+		Vault: &v1alpha1.VaultSecretRef{
+			Address: v.Address,
+			Mount:   v.Mount,
+			Path:    v.Path,
+		},
+	}, nil
+}
+
+// read returns the data of the current version of the secret. Returns false if the secret doesn't exist.
+func (v *VaultSink) read(ctx context.Context) (map[string]string, bool, error) {
+	res := struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}{}
+
+	found, err := v.do(ctx, http.MethodGet, "data", nil, &res)
+
+	return res.Data.Data, found, err
+}
+
+// Delete removes all versions of the secret.
+func (v *VaultSink) Delete(ctx context.Context) error {
+	_, err := v.do(ctx, http.MethodDelete, "metadata", nil, nil)
+
+	return err
+}
+
+// do sends a request to the data or metadata endpoint of the secret. Returns false if the secret doesn't exist.
+func (v *VaultSink) do(ctx context.Context, method string, endpoint string, body interface{}, out interface{}) (bool, error) {
+	url := strings.TrimSuffix(v.Address, "/") + "/v1/" + strings.Trim(v.Mount, "/") + "/" + endpoint + "/" + strings.Trim(v.Path, "/")
+
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to marshal vault request")
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to create vault request")
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := v.HTTPClient.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to reach vault")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		vaultErr := struct {
+			Errors []string `json:"errors"`
+		}{}
+		_ = json.NewDecoder(res.Body).Decode(&vaultErr)
+
+		return false, errors.Errorf("vault returned %d for %s %s: %s", res.StatusCode, method, url, strings.Join(vaultErr.Errors, ", "))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return false, errors.Wrap(err, "unable to decode vault response")
+		}
+	}
+
+	return true, nil
+}
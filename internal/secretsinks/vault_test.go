@@ -0,0 +1,103 @@
+package secretsinks_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/secretsinks"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/secretsinks/vaulttest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VaultSink", func() {
+	var server *vaulttest.Server
+	var sink *secretsinks.VaultSink
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		server = vaulttest.NewServer("root")
+		sink = &secretsinks.VaultSink{
+			Address:         server.URL,
+			Mount:           "secret",
+			Path:            "cloudflare/token",
+			Token:           "root",
+			ClientIDKey:     "clientId",
+			ClientSecretKey: "clientSecret",
+			HTTPClient:      http.DefaultClient,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("reads nothing before the credentials are written", func() {
+		credentials, err := sink.Read(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(credentials).To(BeNil())
+	})
+
+	It("writes and reads the credentials", func() {
+		ref, err := sink.Write(ctx, secretsinks.Credentials{
+			TokenID:      "id",
+			ClientID:     "client.access",
+			ClientSecret: "secret",
+			Data:         map[string][]byte{".env": []byte("CF_ACCESS_CLIENT_ID=client.access")},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ref.Sink).To(Equal(secretsinks.SinkVault))
+		Expect(ref.Vault.Path).To(Equal("cloudflare/token"))
+		Expect(ref.Name).To(BeEmpty())
+
+		Expect(server.Secret("secret/cloudflare/token")).To(Equal(map[string]interface{}{
+			"serviceTokenID": "id",
+			"clientId":       "client.access",
+			"clientSecret":   "secret",
+			".env":           "CF_ACCESS_CLIENT_ID=client.access",
+		}))
+
+		credentials, err := sink.Read(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(credentials.TokenID).To(Equal("id"))
+		Expect(credentials.ClientID).To(Equal("client.access"))
+		Expect(credentials.ClientSecret).To(Equal("secret"))
+		Expect(credentials.Data).To(HaveKeyWithValue(".env", []byte("CF_ACCESS_CLIENT_ID=client.access")))
+	})
+
+	It("only writes a new version when the credentials change", func() {
+		credentials := secretsinks.Credentials{TokenID: "id", ClientID: "client.access", ClientSecret: "secret"}
+
+		_, err := sink.Write(ctx, credentials)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = sink.Write(ctx, credentials)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Version("secret/cloudflare/token")).To(Equal(1))
+
+		credentials.ClientSecret = "rotated"
+		_, err = sink.Write(ctx, credentials)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Version("secret/cloudflare/token")).To(Equal(2))
+	})
+
+	It("deletes the credentials", func() {
+		_, err := sink.Write(ctx, secretsinks.Credentials{TokenID: "id"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(sink.Delete(ctx)).To(Succeed())
+		Expect(server.Secret("secret/cloudflare/token")).To(BeNil())
+
+		// deleting missing credentials succeeds
+		Expect(sink.Delete(ctx)).To(Succeed())
+	})
+
+	It("reports vault errors", func() {
+		sink.Token = "invalid"
+
+		_, err := sink.Read(ctx)
+		Expect(err).To(MatchError(ContainSubstring("permission denied")))
+
+		_, err = sink.Write(ctx, secretsinks.Credentials{TokenID: "id"})
+		Expect(err).To(HaveOccurred())
+	})
+})
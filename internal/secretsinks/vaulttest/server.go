@@ -0,0 +1,113 @@
+// Package vaulttest provides an in-memory stand-in for the KV version 2 secrets engine of HashiCorp Vault.
+package vaulttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Server serves the data and metadata endpoints of KV version 2 secrets engines for a single token.
+type Server struct {
+	*httptest.Server
+
+	Token string
+
+	mu       sync.Mutex
+	secrets  map[string]map[string]interface{}
+	versions map[string]int
+}
+
+// NewServer starts a server accepting the given token. The caller should call Close when finished.
+func NewServer(token string) *Server {
+	s := &Server{
+		Token:    token,
+		secrets:  map[string]map[string]interface{}{},
+		versions: map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// Secret returns the data of the secret at "<mount>/<path>", nil if it doesn't exist.
+func (s *Server) Secret(path string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.secrets[path]
+}
+
+// Version returns the number of versions written of the secret at "<mount>/<path>" since it was last deleted.
+func (s *Server) Version(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.versions[path]
+}
+
+// DeleteSecret removes the secret at "<mount>/<path>".
+func (s *Server) DeleteSecret(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.secrets, path)
+	delete(s.versions, path)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != s.Token {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+
+		return
+	}
+
+	// /v1/<mount>/<data|metadata>/<path>
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v1/"), "/", 3)
+	if len(parts) != 3 {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+
+		return
+	}
+	key := parts[0] + "/" + parts[2]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case parts[1] == "data" && r.Method == http.MethodGet:
+		data, ok := s.secrets[key]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"data": data}})
+	case parts[1] == "data" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		body := struct {
+			Data map[string]interface{} `json:"data"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{err.Error()}})
+
+			return
+		}
+		s.secrets[key] = body.Data
+		s.versions[key]++
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{}})
+	case parts[1] == "metadata" && r.Method == http.MethodDelete:
+		delete(s.secrets, key)
+		delete(s.versions, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"errors": []string{"unsupported operation"}})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
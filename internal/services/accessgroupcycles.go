@@ -0,0 +1,92 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// AccessGroupGraph holds the references between CloudflareAccessGroups, either by name or with a selector.
+type AccessGroupGraph map[types.NamespacedName][]types.NamespacedName
+
+// NewAccessGroupGraph builds the reference graph of the given CloudflareAccessGroups. A group isn't selected by its
+// own selectors.
+func NewAccessGroupGraph(groups []v1alpha1.CloudflareAccessGroup) AccessGroupGraph {
+	graph := AccessGroupGraph{}
+
+	for index, group := range groups {
+		key := types.NamespacedName{Namespace: group.Namespace, Name: group.Name}
+		policies := []AccessPolicyList{group.Spec}
+		seen := map[types.NamespacedName]bool{}
+
+		for _, ref := range ReferencedResources(policies, group.GetType()) {
+			namespace, name, _ := strings.Cut(ref, "/")
+			seen[types.NamespacedName{Namespace: namespace, Name: name}] = true
+		}
+
+		if len(Selectors(policies, group.GetType())) > 0 {
+			for i := range groups {
+				if i == index {
+					continue
+				}
+
+				if SelectsResource(policies, group.Namespace, group.GetType(), &groups[i]) {
+					seen[types.NamespacedName{Namespace: groups[i].Namespace, Name: groups[i].Name}] = true
+				}
+			}
+		}
+
+		edges := make([]types.NamespacedName, 0, len(seen))
+		for ref := range seen {
+			edges = append(edges, ref)
+		}
+
+		sort.Slice(edges, func(i, j int) bool {
+			return edges[i].String() < edges[j].String()
+		})
+
+		graph[key] = edges
+	}
+
+	return graph
+}
+
+// FindCycle returns the path of a reference cycle going through start, starting and ending with start.
+// Returns nil if start isn't part of a cycle.
+func (g AccessGroupGraph) FindCycle(start types.NamespacedName) []types.NamespacedName {
+	visited := map[types.NamespacedName]bool{}
+
+	var visit func(node types.NamespacedName, path []types.NamespacedName) []types.NamespacedName
+	visit = func(node types.NamespacedName, path []types.NamespacedName) []types.NamespacedName {
+		for _, next := range g[node] {
+			if next == start {
+				return append(path, next)
+			}
+
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+
+			if cycle := visit(next, append(path, next)); cycle != nil {
+				return cycle
+			}
+		}
+
+		return nil
+	}
+
+	return visit(start, []types.NamespacedName{start})
+}
+
+// FormatCycle renders a reference cycle as "ns/a -> ns/b -> ns/a".
+func FormatCycle(cycle []types.NamespacedName) string {
+	refs := make([]string, 0, len(cycle))
+	for _, ref := range cycle {
+		refs = append(refs, ref.String())
+	}
+
+	return strings.Join(refs, " -> ")
+}
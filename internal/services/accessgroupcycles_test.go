@@ -0,0 +1,88 @@
+package services_test
+
+import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("AccessGroupGraph", Label("AccessGroupGraph"), func() {
+	key := func(name string) types.NamespacedName {
+		return types.NamespacedName{Namespace: "default", Name: name}
+	}
+
+	newGroup := func(name string, labels map[string]string, refs ...v1alpha1.AccessGroup) v1alpha1.CloudflareAccessGroup {
+		return v1alpha1.CloudflareAccessGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec: v1alpha1.CloudflareAccessGroupSpec{
+				Name:    name,
+				Include: []v1alpha1.CloudFlareAccessGroupRule{{AccessGroups: refs}},
+			},
+		}
+	}
+
+	byName := func(name string) v1alpha1.AccessGroup {
+		return v1alpha1.AccessGroup{ValueFrom: &v1alpha1.AccessGroupReference{Namespace: "default", Name: name}}
+	}
+
+	byLabel := func(value string) v1alpha1.AccessGroup {
+		return v1alpha1.AccessGroup{Selector: &v1alpha1.ResourceSelector{
+			LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": value}},
+		}}
+	}
+
+	It("should find a cycle between two groups", func() {
+		graph := services.NewAccessGroupGraph([]v1alpha1.CloudflareAccessGroup{
+			newGroup("a", nil, byName("b")),
+			newGroup("b", nil, byName("a")),
+		})
+
+		cycle := graph.FindCycle(key("a"))
+		Expect(cycle).To(Equal([]types.NamespacedName{key("a"), key("b"), key("a")}))
+		Expect(services.FormatCycle(cycle)).To(Equal("default/a -> default/b -> default/a"))
+	})
+
+	It("should find a group referencing itself by name", func() {
+		graph := services.NewAccessGroupGraph([]v1alpha1.CloudflareAccessGroup{
+			newGroup("a", nil, byName("a")),
+		})
+
+		Expect(services.FormatCycle(graph.FindCycle(key("a")))).To(Equal("default/a -> default/a"))
+	})
+
+	It("should find a cycle created by a selector", func() {
+		graph := services.NewAccessGroupGraph([]v1alpha1.CloudflareAccessGroup{
+			newGroup("a", map[string]string{"team": "a"}, byLabel("b")),
+			newGroup("b", map[string]string{"team": "b"}, byName("c")),
+			newGroup("c", nil, byLabel("a")),
+		})
+
+		Expect(services.FormatCycle(graph.FindCycle(key("b")))).To(Equal("default/b -> default/c -> default/a -> default/b"))
+	})
+
+	It("should not report a diamond as a cycle", func() {
+		graph := services.NewAccessGroupGraph([]v1alpha1.CloudflareAccessGroup{
+			newGroup("a", nil, byName("b"), byName("c")),
+			newGroup("b", nil, byName("d")),
+			newGroup("c", nil, byName("d")),
+			newGroup("d", nil),
+		})
+
+		for _, name := range []string{"a", "b", "c", "d"} {
+			Expect(graph.FindCycle(key(name))).To(BeNil(), name)
+		}
+	})
+
+	It("should not select a group with its own selector", func() {
+		graph := services.NewAccessGroupGraph([]v1alpha1.CloudflareAccessGroup{
+			newGroup("a", map[string]string{"team": "x"}, byLabel("x")),
+			newGroup("b", map[string]string{"team": "x"}),
+		})
+
+		Expect(graph[key("a")]).To(Equal([]types.NamespacedName{key("b")}))
+		Expect(graph.FindCycle(key("a"))).To(BeNil())
+	})
+})
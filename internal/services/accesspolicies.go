@@ -2,16 +2,39 @@ package services
 
 import (
 	"context"
+	"net/url"
+	"sort"
 
 	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ReferenceIndexes maps the kind of a referenced resource to the field index of the access groups and applications
+// referencing it with valueFrom.
+var ReferenceIndexes = map[string]string{
+	"CloudflareAccessGroup":       ".spec.references.accessGroups",
+	"CloudflareServiceToken":      ".spec.references.serviceTokens",
+	"CloudflareDevicePostureRule": ".spec.references.devicePostureRules",
+	"CloudflareList":              ".spec.references.lists",
+	"ConfigMap":                   ".spec.references.configMaps",
+	"Secret":                      ".spec.references.secrets",
+	"Service":                     ".spec.references.services",
+}
+
 type AccessPolicyService struct {
 	Client client.Client
 	Log    logr.Logger
+
+	// Kind, Namespace and Name of the resource holding the references;
+	// references to other namespaces must be permitted by a CloudflareReferenceGrant
+	// and an access group is never selected by its own selectors
+	Kind      string
+	Namespace string
+	Name      string
 }
 
 type AccessPolicyList interface {
@@ -46,6 +69,10 @@ func (s *AccessPolicyService) PopulateAccessPolicyReferences(ctx context.Context
 			for j, field := range *fields {
 				for k, token := range field.AccessGroups {
 					if token.ValueFrom != nil {
+						if err := s.authorizeReference(ctx, "CloudflareAccessGroup", token.ValueFrom.ToNamespacedName()); err != nil {
+							return err
+						}
+
 						accessGroup := &v1alpha1.CloudflareAccessGroup{}
 						if err := s.Client.Get(ctx, token.ValueFrom.ToNamespacedName(), accessGroup); err != nil {
 							return errors.Wrapf(err, "unable to reference CloudflareAccessGroup %s - %s", token.ValueFrom.Name, token.ValueFrom.Namespace)
@@ -55,19 +82,446 @@ func (s *AccessPolicyService) PopulateAccessPolicyReferences(ctx context.Context
 					}
 				}
 
-				for k, token := range field.ServiceToken {
+				accessGroups, err := s.expandAccessGroupSelectors(ctx, (*fields)[j].AccessGroups)
+				if err != nil {
+					return err
+				}
+				(*fields)[j].AccessGroups = accessGroups
+
+				referencedTokens := make([]v1alpha1.ServiceToken, 0, len(field.ServiceToken))
+				for _, token := range field.ServiceToken {
 					if token.ValueFrom != nil {
+						if err := s.authorizeReference(ctx, "CloudflareServiceToken", token.ValueFrom.ToNamespacedName()); err != nil {
+							return err
+						}
+
 						serviceToken := &v1alpha1.CloudflareServiceToken{}
 						if err := s.Client.Get(ctx, token.ValueFrom.ToNamespacedName(), serviceToken); err != nil {
 							return errors.Wrapf(err, "unable to reference CloudflareServiceToken %s - %s", token.ValueFrom.Name, token.ValueFrom.Namespace)
 						}
 
-						(*fields)[j].ServiceToken[k].Value = serviceToken.Status.ServiceTokenID
+						token.Value = serviceToken.Status.ServiceTokenID
+						referencedTokens = append(referencedTokens, token)
+						referencedTokens = append(referencedTokens, previousServiceTokens(serviceToken)...)
+
+						continue
+					}
+
+					referencedTokens = append(referencedTokens, token)
+				}
+
+				serviceTokens, err := s.expandServiceTokenSelectors(ctx, referencedTokens)
+				if err != nil {
+					return err
+				}
+				(*fields)[j].ServiceToken = serviceTokens
+
+				for k, posture := range field.DevicePosture {
+					if posture.ValueFrom != nil {
+						if err := s.authorizeReference(ctx, "CloudflareDevicePostureRule", posture.ValueFrom.ToNamespacedName()); err != nil {
+							return err
+						}
+
+						postureRule := &v1alpha1.CloudflareDevicePostureRule{}
+						if err := s.Client.Get(ctx, posture.ValueFrom.ToNamespacedName(), postureRule); err != nil {
+							return errors.Wrapf(err, "unable to reference CloudflareDevicePostureRule %s - %s", posture.ValueFrom.Name, posture.ValueFrom.Namespace)
+						}
+
+						(*fields)[j].DevicePosture[k].Value = postureRule.Status.DevicePostureRuleID
+					}
+				}
+
+				for k, list := range field.EmailList {
+					if list.ValueFrom != nil {
+						listID, err := s.getListID(ctx, list.ValueFrom, v1alpha1.ListTypeEmail)
+						if err != nil {
+							return err
+						}
+
+						(*fields)[j].EmailList[k].Value = listID
+					}
+				}
+
+				for k, list := range field.IPList {
+					if list.ValueFrom != nil {
+						listID, err := s.getListID(ctx, list.ValueFrom, v1alpha1.ListTypeIP)
+						if err != nil {
+							return err
+						}
+
+						(*fields)[j].IPList[k].Value = listID
+					}
+				}
+
+				for k, evaluation := range field.ExternalEvaluation {
+					if evaluation.ValueFrom != nil {
+						evaluateURL, keysURL, err := s.getExternalEvaluationURLs(ctx, evaluation.ValueFrom)
+						if err != nil {
+							return err
+						}
+
+						(*fields)[j].ExternalEvaluation[k].EvaluateURL = evaluateURL
+						(*fields)[j].ExternalEvaluation[k].KeysURL = keysURL
 					}
 				}
+
+				if err := s.populateRuleValues(ctx, &(*fields)[j]); err != nil {
+					return err
+				}
 			}
 		}
 	}
 
 	return nil
 }
+
+// expandAccessGroupSelectors replaces every selector with the IDs of the matching CloudflareAccessGroups.
+// Access groups that don't exist in cloudflare yet, or that no CloudflareReferenceGrant permits, are skipped, as
+// well as the access group holding the selector.
+func (s *AccessPolicyService) expandAccessGroupSelectors(ctx context.Context, groups []v1alpha1.AccessGroup) ([]v1alpha1.AccessGroup, error) {
+	result := make([]v1alpha1.AccessGroup, 0, len(groups))
+
+	for _, group := range groups {
+		if group.Selector == nil {
+			result = append(result, group)
+
+			continue
+		}
+
+		selector, err := group.Selector.ToSelector()
+		if err != nil {
+			return nil, err
+		}
+
+		namespace := group.Selector.GetNamespace(s.Namespace)
+		accessGroups := &v1alpha1.CloudflareAccessGroupList{}
+		if err := s.Client.List(ctx, accessGroups, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, errors.Wrapf(err, "unable to select CloudflareAccessGroups in %s", namespace)
+		}
+
+		sort.Slice(accessGroups.Items, func(i, j int) bool {
+			return accessGroups.Items[i].Name < accessGroups.Items[j].Name
+		})
+
+		for _, accessGroup := range accessGroups.Items {
+			if s.Kind == accessGroup.GetType() && accessGroup.Namespace == s.Namespace && accessGroup.Name == s.Name {
+				continue
+			}
+
+			permitted, err := s.permitted(ctx, "CloudflareAccessGroup", types.NamespacedName{Namespace: namespace, Name: accessGroup.Name})
+			if err != nil {
+				return nil, err
+			}
+
+			if !permitted {
+				s.Log.Info("skipping selected access group which isn't permitted by a CloudflareReferenceGrant", "name", accessGroup.Name, "namespace", namespace)
+
+				continue
+			}
+
+			if accessGroup.Status.AccessGroupID != "" {
+				result = append(result, v1alpha1.AccessGroup{Value: accessGroup.Status.AccessGroupID})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// expandServiceTokenSelectors replaces every selector with the IDs of the matching CloudflareServiceTokens.
+// Service tokens that don't exist in cloudflare yet, or that no CloudflareReferenceGrant permits, are skipped.
+func (s *AccessPolicyService) expandServiceTokenSelectors(ctx context.Context, tokens []v1alpha1.ServiceToken) ([]v1alpha1.ServiceToken, error) {
+	result := make([]v1alpha1.ServiceToken, 0, len(tokens))
+
+	for _, token := range tokens {
+		if token.Selector == nil {
+			result = append(result, token)
+
+			continue
+		}
+
+		selector, err := token.Selector.ToSelector()
+		if err != nil {
+			return nil, err
+		}
+
+		namespace := token.Selector.GetNamespace(s.Namespace)
+		serviceTokens := &v1alpha1.CloudflareServiceTokenList{}
+		if err := s.Client.List(ctx, serviceTokens, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, errors.Wrapf(err, "unable to select CloudflareServiceTokens in %s", namespace)
+		}
+
+		sort.Slice(serviceTokens.Items, func(i, j int) bool {
+			return serviceTokens.Items[i].Name < serviceTokens.Items[j].Name
+		})
+
+		for _, serviceToken := range serviceTokens.Items {
+			permitted, err := s.permitted(ctx, "CloudflareServiceToken", types.NamespacedName{Namespace: namespace, Name: serviceToken.Name})
+			if err != nil {
+				return nil, err
+			}
+
+			if !permitted {
+				s.Log.Info("skipping selected service token which isn't permitted by a CloudflareReferenceGrant", "name", serviceToken.Name, "namespace", namespace)
+
+				continue
+			}
+
+			if serviceToken.Status.ServiceTokenID != "" {
+				result = append(result, v1alpha1.ServiceToken{Value: serviceToken.Status.ServiceTokenID})
+				result = append(result, previousServiceTokens(&serviceToken)...)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// previousServiceTokens returns the previous token of an overlapping rotation, which stays valid until the
+// end of the overlap window.
+func previousServiceTokens(serviceToken *v1alpha1.CloudflareServiceToken) []v1alpha1.ServiceToken {
+	if serviceToken.Status.PreviousServiceTokenID == "" {
+		return nil
+	}
+
+	return []v1alpha1.ServiceToken{{Value: serviceToken.Status.PreviousServiceTokenID}}
+}
+
+// ReferencedResources returns the namespaced names of all resources of the given kind referenced with valueFrom.
+// nolint: gocognit,cyclop
+func ReferencedResources(policyList []AccessPolicyList, kind string) []string {
+	refs := []string{}
+
+	for _, policy := range policyList {
+		for _, rules := range [][]v1alpha1.CloudFlareAccessGroupRule{policy.GetInclude(), policy.GetExclude(), policy.GetRequire()} {
+			for _, rule := range rules {
+				switch kind {
+				case "CloudflareAccessGroup":
+					for _, group := range rule.AccessGroups {
+						if group.ValueFrom != nil {
+							refs = append(refs, group.ValueFrom.ToNamespacedName().String())
+						}
+					}
+				case "CloudflareServiceToken":
+					for _, token := range rule.ServiceToken {
+						if token.ValueFrom != nil {
+							refs = append(refs, token.ValueFrom.ToNamespacedName().String())
+						}
+					}
+				case "CloudflareDevicePostureRule":
+					for _, posture := range rule.DevicePosture {
+						if posture.ValueFrom != nil {
+							refs = append(refs, posture.ValueFrom.ToNamespacedName().String())
+						}
+					}
+				case "CloudflareList":
+					for _, list := range append(append([]v1alpha1.List{}, rule.EmailList...), rule.IPList...) {
+						if list.ValueFrom != nil {
+							refs = append(refs, list.ValueFrom.ToNamespacedName().String())
+						}
+					}
+				case "ConfigMap", "Secret", "Service":
+					refs = append(refs, referencedSources(rule, kind)...)
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+// Selectors returns the selectors of all references to resources of the given kind.
+func Selectors(policyList []AccessPolicyList, kind string) []*v1alpha1.ResourceSelector {
+	selectors := []*v1alpha1.ResourceSelector{}
+
+	for _, policy := range policyList {
+		for _, rules := range [][]v1alpha1.CloudFlareAccessGroupRule{policy.GetInclude(), policy.GetExclude(), policy.GetRequire()} {
+			for _, rule := range rules {
+				switch kind {
+				case "CloudflareAccessGroup":
+					for _, group := range rule.AccessGroups {
+						if group.Selector != nil {
+							selectors = append(selectors, group.Selector)
+						}
+					}
+				case "CloudflareServiceToken":
+					for _, token := range rule.ServiceToken {
+						if token.Selector != nil {
+							selectors = append(selectors, token.Selector)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return selectors
+}
+
+// SelectsResource returns true if any of the selectors in the policies selects the given resource.
+func SelectsResource(policyList []AccessPolicyList, namespace string, kind string, obj client.Object) bool {
+	for _, selector := range Selectors(policyList, kind) {
+		if matches, err := selector.Matches(namespace, obj.GetNamespace(), obj.GetLabels()); err == nil && matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindDependents returns the access groups and applications referencing the given resource of the given kind.
+// References by name are looked up with the ReferenceIndexes, so c has to be a cached client of a manager running
+// the access group and application controllers; only kinds which can be selected are matched against every resource.
+func FindDependents(ctx context.Context, c client.Client, kind string, obj client.Object) ([]v1alpha1.ResourceReference, error) {
+	dependents := map[v1alpha1.ResourceReference]bool{}
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	selectable := kind == "CloudflareAccessGroup" || kind == "CloudflareServiceToken"
+
+	groups := &v1alpha1.CloudflareAccessGroupList{}
+	if err := c.List(ctx, groups, client.MatchingFields{ReferenceIndexes[kind]: key.String()}); err != nil {
+		return nil, errors.Wrap(err, "unable to list referencing CloudflareAccessGroups")
+	}
+
+	if selectable {
+		selecting := &v1alpha1.CloudflareAccessGroupList{}
+		if err := c.List(ctx, selecting); err != nil {
+			return nil, errors.Wrap(err, "unable to list CloudflareAccessGroups")
+		}
+
+		for _, group := range selecting.Items {
+			if SelectsResource([]AccessPolicyList{group.Spec}, group.Namespace, kind, obj) {
+				groups.Items = append(groups.Items, group)
+			}
+		}
+	}
+
+	for _, group := range groups.Items {
+		if kind == group.GetType() && group.Namespace == key.Namespace && group.Name == key.Name {
+			continue
+		}
+
+		dependents[v1alpha1.ResourceReference{Kind: group.GetType(), Namespace: group.Namespace, Name: group.Name}] = true
+	}
+
+	apps := &v1alpha1.CloudflareAccessApplicationList{}
+	if err := c.List(ctx, apps, client.MatchingFields{ReferenceIndexes[kind]: key.String()}); err != nil {
+		return nil, errors.Wrap(err, "unable to list referencing CloudflareAccessApplications")
+	}
+
+	if selectable {
+		selecting := &v1alpha1.CloudflareAccessApplicationList{}
+		if err := c.List(ctx, selecting); err != nil {
+			return nil, errors.Wrap(err, "unable to list CloudflareAccessApplications")
+		}
+
+		for _, app := range selecting.Items {
+			if SelectsResource(ToAccessPolicyList(app.Spec.Policies), app.Namespace, kind, obj) {
+				apps.Items = append(apps.Items, app)
+			}
+		}
+	}
+
+	for _, app := range apps.Items {
+		dependents[v1alpha1.ResourceReference{Kind: app.GetType(), Namespace: app.Namespace, Name: app.Name}] = true
+	}
+
+	sorted := make([]v1alpha1.ResourceReference, 0, len(dependents))
+	for dependent := range dependents {
+		sorted = append(sorted, dependent)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Kind != sorted[j].Kind {
+			return sorted[i].Kind < sorted[j].Kind
+		}
+		if sorted[i].Namespace != sorted[j].Namespace {
+			return sorted[i].Namespace < sorted[j].Namespace
+		}
+
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted, nil
+}
+
+func (s *AccessPolicyService) getListID(ctx context.Context, ref *v1alpha1.ListReference, listType string) (string, error) {
+	if err := s.authorizeReference(ctx, "CloudflareList", ref.ToNamespacedName()); err != nil {
+		return "", err
+	}
+
+	list := &v1alpha1.CloudflareList{}
+	if err := s.Client.Get(ctx, ref.ToNamespacedName(), list); err != nil {
+		return "", errors.Wrapf(err, "unable to reference CloudflareList %s - %s", ref.Name, ref.Namespace)
+	}
+
+	if list.Spec.Type != listType {
+		return "", errors.Errorf("CloudflareList %s - %s is of type %s, expected %s", ref.Name, ref.Namespace, list.Spec.Type, listType)
+	}
+
+	return list.Status.ListID, nil
+}
+
+// getExternalEvaluationURLs validates the referenced Service and
+// returns the public evaluation and keys URLs under the configured hostname.
+func (s *AccessPolicyService) getExternalEvaluationURLs(ctx context.Context, ref *v1alpha1.ExternalEvaluationReference) (string, string, error) {
+	if err := s.authorizeReference(ctx, "Service", ref.Service.ToNamespacedName()); err != nil {
+		return "", "", err
+	}
+
+	service := &corev1.Service{}
+	if err := s.Client.Get(ctx, ref.Service.ToNamespacedName(), service); err != nil {
+		return "", "", errors.Wrapf(err, "unable to reference Service %s - %s", ref.Service.Name, ref.Service.Namespace)
+	}
+
+	if ref.Hostname == "" {
+		return "", "", errors.Errorf("hostname is required to expose Service %s - %s", ref.Service.Name, ref.Service.Namespace)
+	}
+
+	evaluatePath := ref.EvaluatePath
+	if evaluatePath == "" {
+		evaluatePath = "/"
+	}
+
+	keysPath := ref.KeysPath
+	if keysPath == "" {
+		keysPath = "/keys"
+	}
+
+	evaluateURL := url.URL{Scheme: "https", Host: ref.Hostname, Path: evaluatePath}
+	keysURL := url.URL{Scheme: "https", Host: ref.Hostname, Path: keysPath}
+
+	return evaluateURL.String(), keysURL.String(), nil
+}
+
+// authorizeReference checks that a reference to a resource in another namespace is permitted by a
+// CloudflareReferenceGrant in the namespace of the referenced resource.
+func (s *AccessPolicyService) authorizeReference(ctx context.Context, toKind string, ref types.NamespacedName) error {
+	permitted, err := s.permitted(ctx, toKind, ref)
+	if err != nil || permitted {
+		return err
+	}
+
+	return errors.Errorf("reference to %s %s - %s is not allowed: no CloudflareReferenceGrant in namespace %s permits %s from namespace %s",
+		toKind, ref.Name, ref.Namespace, ref.Namespace, s.Kind, s.Namespace)
+}
+
+// permitted returns true if the resource is in the same namespace or a CloudflareReferenceGrant in its namespace
+// permits the reference.
+func (s *AccessPolicyService) permitted(ctx context.Context, toKind string, ref types.NamespacedName) (bool, error) {
+	if ref.Namespace == s.Namespace {
+		return true, nil
+	}
+
+	grants := &v1alpha1.CloudflareReferenceGrantList{}
+	if err := s.Client.List(ctx, grants, client.InNamespace(ref.Namespace)); err != nil {
+		return false, errors.Wrapf(err, "unable to list CloudflareReferenceGrants in %s", ref.Namespace)
+	}
+
+	for _, grant := range grants.Items {
+		if grant.Permits(s.Kind, s.Namespace, toKind, ref.Name) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,6 @@
+package services
+
+var (
+	ToCIDR       = toCIDR
+	AppendUnique = appendUnique
+)
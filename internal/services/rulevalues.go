@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// populateRuleValues appends the values read from the emailsFrom, emailDomainsFrom and ipRangesFrom sources of the rule.
+func (s *AccessPolicyService) populateRuleValues(ctx context.Context, rule *v1alpha1.CloudFlareAccessGroupRule) error {
+	emails, err := s.resolveRuleValues(ctx, rule.EmailsFrom, false)
+	if err != nil {
+		return err
+	}
+	rule.Emails = appendUnique(rule.Emails, emails...)
+
+	domains, err := s.resolveRuleValues(ctx, rule.EmailDomainsFrom, false)
+	if err != nil {
+		return err
+	}
+	rule.EmailDomains = appendUnique(rule.EmailDomains, domains...)
+
+	ipRanges, err := s.resolveRuleValues(ctx, rule.IPRangesFrom, true)
+	if err != nil {
+		return err
+	}
+	rule.IPRanges = appendUnique(rule.IPRanges, ipRanges...)
+
+	return nil
+}
+
+// resolveRuleValues reads the values of all sources; node and Service sources are only allowed for IP ranges.
+func (s *AccessPolicyService) resolveRuleValues(ctx context.Context, sources []v1alpha1.RuleValuesSource, ipRanges bool) ([]string, error) {
+	values := []string{}
+
+	for _, source := range sources {
+		var resolved []string
+		var err error
+
+		switch {
+		case source.ConfigMapKeyRef != nil:
+			resolved, err = s.getConfigMapValues(ctx, source.ConfigMapKeyRef)
+		case source.SecretKeyRef != nil:
+			resolved, err = s.getSecretValues(ctx, source.SecretKeyRef)
+		case source.NodeExternalIPs != nil && ipRanges:
+			resolved, err = s.getNodeExternalIPs(ctx, source.NodeExternalIPs)
+		case source.ServiceLoadBalancer != nil && ipRanges:
+			resolved, err = s.getServiceLoadBalancerIPs(ctx, source.ServiceLoadBalancer)
+		case source.NodeExternalIPs != nil || source.ServiceLoadBalancer != nil:
+			return nil, errors.New("nodeExternalIPs and serviceLoadBalancer are only supported by ipRangesFrom")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, resolved...)
+	}
+
+	return values, nil
+}
+
+func (s *AccessPolicyService) getConfigMapValues(ctx context.Context, ref *v1alpha1.KeyReference) ([]string, error) {
+	if err := s.authorizeReference(ctx, "ConfigMap", ref.ToNamespacedName()); err != nil {
+		return nil, err
+	}
+
+	optional := ref.Optional != nil && *ref.Optional
+
+	configMap := &corev1.ConfigMap{}
+	if err := s.Client.Get(ctx, ref.ToNamespacedName(), configMap); err != nil {
+		if k8serrors.IsNotFound(err) && optional {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "unable to reference ConfigMap %s - %s", ref.Name, ref.Namespace)
+	}
+
+	value, ok := configMap.Data[ref.Key]
+	if !ok {
+		if optional {
+			return nil, nil
+		}
+
+		return nil, errors.Errorf("key %s not found in ConfigMap %s - %s", ref.Key, ref.Name, ref.Namespace)
+	}
+
+	return SplitValues(value), nil
+}
+
+func (s *AccessPolicyService) getSecretValues(ctx context.Context, ref *v1alpha1.KeyReference) ([]string, error) {
+	if err := s.authorizeReference(ctx, "Secret", ref.ToNamespacedName()); err != nil {
+		return nil, err
+	}
+
+	optional := ref.Optional != nil && *ref.Optional
+
+	secret := &corev1.Secret{}
+	if err := s.Client.Get(ctx, ref.ToNamespacedName(), secret); err != nil {
+		if k8serrors.IsNotFound(err) && optional {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "unable to reference Secret %s - %s", ref.Name, ref.Namespace)
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		if optional {
+			return nil, nil
+		}
+
+		return nil, errors.Errorf("key %s not found in Secret %s - %s", ref.Key, ref.Name, ref.Namespace)
+	}
+
+	return SplitValues(string(value)), nil
+}
+
+// getNodeExternalIPs returns the external IPs of the matching nodes as single address CIDR blocks.
+func (s *AccessPolicyService) getNodeExternalIPs(ctx context.Context, labelSelector *metav1.LabelSelector) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid node selector")
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := s.Client.List(ctx, nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrap(err, "unable to list nodes")
+	}
+
+	ips := []string{}
+	for _, node := range nodes.Items {
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeExternalIP {
+				if cidr := toCIDR(address.Address); cidr != "" {
+					ips = append(ips, cidr)
+				}
+			}
+		}
+	}
+
+	return ips, nil
+}
+
+// getServiceLoadBalancerIPs returns the load balancer ingress IPs of the Service as single address CIDR blocks.
+func (s *AccessPolicyService) getServiceLoadBalancerIPs(ctx context.Context, ref *v1alpha1.ServiceReference) ([]string, error) {
+	if err := s.authorizeReference(ctx, "Service", ref.ToNamespacedName()); err != nil {
+		return nil, err
+	}
+
+	service := &corev1.Service{}
+	if err := s.Client.Get(ctx, ref.ToNamespacedName(), service); err != nil {
+		return nil, errors.Wrapf(err, "unable to reference Service %s - %s", ref.Name, ref.Namespace)
+	}
+
+	ips := []string{}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if cidr := toCIDR(ingress.IP); cidr != "" {
+			ips = append(ips, cidr)
+		}
+	}
+
+	return ips, nil
+}
+
+// referencedSources returns the ConfigMaps, Secrets or Services referenced by the rule as "namespace/name".
+func referencedSources(rule v1alpha1.CloudFlareAccessGroupRule, kind string) []string {
+	refs := []string{}
+
+	sources := append(append(append([]v1alpha1.RuleValuesSource{}, rule.EmailsFrom...), rule.EmailDomainsFrom...), rule.IPRangesFrom...)
+	for _, source := range sources {
+		switch {
+		case kind == "ConfigMap" && source.ConfigMapKeyRef != nil:
+			refs = append(refs, source.ConfigMapKeyRef.ToNamespacedName().String())
+		case kind == "Secret" && source.SecretKeyRef != nil:
+			refs = append(refs, source.SecretKeyRef.ToNamespacedName().String())
+		case kind == "Service" && source.ServiceLoadBalancer != nil:
+			refs = append(refs, source.ServiceLoadBalancer.ToNamespacedName().String())
+		}
+	}
+
+	for _, evaluation := range rule.ExternalEvaluation {
+		if evaluation.ValueFrom == nil {
+			continue
+		}
+
+		if kind == "Service" {
+			refs = append(refs, evaluation.ValueFrom.Service.ToNamespacedName().String())
+		}
+	}
+
+	return refs
+}
+
+// UsesNodeExternalIPs returns true if any rule of the policies reads the external IPs of nodes.
+func UsesNodeExternalIPs(policyList []AccessPolicyList) bool {
+	for _, policy := range policyList {
+		for _, rules := range [][]v1alpha1.CloudFlareAccessGroupRule{policy.GetInclude(), policy.GetExclude(), policy.GetRequire()} {
+			for _, rule := range rules {
+				for _, source := range rule.IPRangesFrom {
+					if source.NodeExternalIPs != nil {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// SelectsNode returns true if a nodeExternalIPs source of the policies selects the node. An invalid selector
+// selects every node so that the error is reported by the reconciliation.
+func SelectsNode(policyList []AccessPolicyList, node client.Object) bool {
+	for _, policy := range policyList {
+		for _, rules := range [][]v1alpha1.CloudFlareAccessGroupRule{policy.GetInclude(), policy.GetExclude(), policy.GetRequire()} {
+			for _, rule := range rules {
+				for _, source := range rule.IPRangesFrom {
+					if source.NodeExternalIPs == nil {
+						continue
+					}
+
+					selector, err := metav1.LabelSelectorAsSelector(source.NodeExternalIPs)
+					if err != nil || selector.Matches(labels.Set(node.GetLabels())) {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// SplitValues splits a multi-line value, skipping empty lines and comments.
+func SplitValues(value string) []string {
+	values := []string{}
+
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		values = append(values, line)
+	}
+
+	return values
+}
+
+func toCIDR(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+
+	return ip.String() + "/128"
+}
+
+func appendUnique(values []string, additional ...string) []string {
+	seen := map[string]bool{}
+	for _, value := range values {
+		seen[value] = true
+	}
+
+	for _, value := range additional {
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
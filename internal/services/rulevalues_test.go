@@ -0,0 +1,38 @@
+package services_test
+
+import (
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RuleValues", Label("RuleValues"), func() {
+	DescribeTable("SplitValues should skip comments and blank lines",
+		func(value string, expected []string) {
+			Expect(services.SplitValues(value)).To(Equal(expected))
+		},
+		Entry("empty", "", []string{}),
+		Entry("single value", "first@test.com", []string{"first@test.com"}),
+		Entry("multiple lines", "first@test.com\nsecond@test.com\n", []string{"first@test.com", "second@test.com"}),
+		Entry("blank lines and spaces", "\n  first@test.com  \n\n\t\nsecond@test.com", []string{"first@test.com", "second@test.com"}),
+		Entry("comments", "# admins\nfirst@test.com\n  # disabled\nsecond@test.com", []string{"first@test.com", "second@test.com"}),
+		Entry("windows line endings", "10.0.0.0/8\r\n192.168.0.0/16\r\n", []string{"10.0.0.0/8", "192.168.0.0/16"}),
+	)
+
+	DescribeTable("toCIDR should convert addresses to single address blocks",
+		func(address string, expected string) {
+			Expect(services.ToCIDR(address)).To(Equal(expected))
+		},
+		Entry("IPv4", "203.0.113.10", "203.0.113.10/32"),
+		Entry("IPv6", "2001:db8::1", "2001:db8::1/128"),
+		Entry("IPv6 normalized", "2001:0db8:0000::0001", "2001:db8::1/128"),
+		Entry("IPv4-mapped IPv6", "::ffff:203.0.113.10", "203.0.113.10/32"),
+		Entry("hostname", "lb.example.com", ""),
+		Entry("empty", "", ""),
+	)
+
+	It("appendUnique should skip values already present", func() {
+		Expect(services.AppendUnique([]string{"a", "b"}, "b", "c", "c", "a", "d")).To(Equal([]string{"a", "b", "c", "d"}))
+		Expect(services.AppendUnique(nil, "a", "a")).To(Equal([]string{"a"}))
+	})
+})
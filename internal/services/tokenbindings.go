@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ServiceTokenBindings returns the service tokens binding the application, whether they are permitted to, and the
+// ids granted access by the managed policy. The previous token of an overlapping rotation stays bound until the
+// end of the overlap window.
+func ServiceTokenBindings(ctx context.Context, c client.Client, app *v1alpha1.CloudflareAccessApplication) ([]v1alpha1.ServiceTokenBindingStatus, []string, error) {
+	tokens := &v1alpha1.CloudflareServiceTokenList{}
+	if err := c.List(ctx, tokens); err != nil {
+		return nil, nil, errors.Wrap(err, "unable to list CloudflareServiceTokens")
+	}
+
+	sort.Slice(tokens.Items, func(i, j int) bool {
+		if tokens.Items[i].Namespace != tokens.Items[j].Namespace {
+			return tokens.Items[i].Namespace < tokens.Items[j].Namespace
+		}
+
+		return tokens.Items[i].Name < tokens.Items[j].Name
+	})
+
+	bindings := []v1alpha1.ServiceTokenBindingStatus{}
+	ids := []string{}
+
+	for i := range tokens.Items {
+		token := &tokens.Items[i]
+		if !token.BindsApplication(app.Namespace, app.Name) || token.UnderDeletion() {
+			continue
+		}
+
+		message, err := app.PermitsServiceTokenBinding(token)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		bindings = append(bindings, v1alpha1.ServiceTokenBindingStatus{
+			Namespace: token.Namespace,
+			Name:      token.Name,
+			Bound:     message == "",
+			Message:   message,
+		})
+
+		if message != "" || token.Status.ServiceTokenID == "" {
+			continue
+		}
+
+		ids = append(ids, token.Status.ServiceTokenID)
+		for _, previous := range previousServiceTokens(token) {
+			ids = append(ids, previous.Value)
+		}
+	}
+
+	return bindings, ids, nil
+}
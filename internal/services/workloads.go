@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Workload is a Deployment, StatefulSet or DaemonSet with its pod template.
+type Workload struct {
+	Kind     string
+	Object   client.Object
+	Template *corev1.PodTemplateSpec
+}
+
+// ConsumingWorkloads returns the workloads in the namespace consuming the service token: the workloads labelled
+// with the name of the token, and the workloads reading the secret through env or volumes if secretName is set.
+func ConsumingWorkloads(ctx context.Context, c client.Client, namespace string, tokenName string, secretName string) ([]Workload, error) {
+	workloads := []Workload{}
+
+	deployments := &appsv1.DeploymentList{}
+	if err := c.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return nil, errors.Wrapf(err, "unable to list deployments in %s", namespace)
+	}
+	for i := range deployments.Items {
+		workloads = append(workloads, Workload{Kind: "Deployment", Object: &deployments.Items[i], Template: &deployments.Items[i].Spec.Template})
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := c.List(ctx, statefulSets, client.InNamespace(namespace)); err != nil {
+		return nil, errors.Wrapf(err, "unable to list statefulsets in %s", namespace)
+	}
+	for i := range statefulSets.Items {
+		workloads = append(workloads, Workload{Kind: "StatefulSet", Object: &statefulSets.Items[i], Template: &statefulSets.Items[i].Spec.Template})
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := c.List(ctx, daemonSets, client.InNamespace(namespace)); err != nil {
+		return nil, errors.Wrapf(err, "unable to list daemonsets in %s", namespace)
+	}
+	for i := range daemonSets.Items {
+		workloads = append(workloads, Workload{Kind: "DaemonSet", Object: &daemonSets.Items[i], Template: &daemonSets.Items[i].Spec.Template})
+	}
+
+	consuming := []Workload{}
+	for _, workload := range workloads {
+		if workload.Object.GetLabels()[v1alpha1.LabelRestartOnRotation] == tokenName ||
+			(secretName != "" && podTemplateReferencesSecret(workload.Template, secretName)) {
+			consuming = append(consuming, workload)
+		}
+	}
+
+	return consuming, nil
+}
+
+// RestartWorkload sets the credentials hash annotation of the pod template, which rolls the pods of the workload.
+// Returns false if the pod template already has the hash.
+func RestartWorkload(ctx context.Context, c client.Client, workload Workload, hash string) (bool, error) {
+	if workload.Template.Annotations[v1alpha1.AnnotationCredentialsHash] == hash {
+		return false, nil
+	}
+
+	patch := client.MergeFrom(workload.Object.DeepCopyObject().(client.Object))
+
+	if workload.Template.Annotations == nil {
+		workload.Template.Annotations = map[string]string{}
+	}
+	workload.Template.Annotations[v1alpha1.AnnotationCredentialsHash] = hash
+
+	if err := c.Patch(ctx, workload.Object, patch); err != nil {
+		return false, errors.Wrapf(err, "unable to restart %s %s/%s", workload.Kind, workload.Object.GetNamespace(), workload.Object.GetName())
+	}
+
+	return true, nil
+}
+
+// podTemplateReferencesSecret returns true if a container reads the secret through env or the pod mounts it.
+func podTemplateReferencesSecret(template *corev1.PodTemplateSpec, secretName string) bool {
+	for _, volume := range template.Spec.Volumes {
+		if volume.Secret != nil && volume.Secret.SecretName == secretName {
+			return true
+		}
+
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil && source.Secret.Name == secretName {
+					return true
+				}
+			}
+		}
+	}
+
+	containers := append([]corev1.Container{}, template.Spec.InitContainers...)
+	containers = append(containers, template.Spec.Containers...)
+
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil && envFrom.SecretRef.Name == secretName {
+				return true
+			}
+		}
+
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == secretName {
+				return true
+			}
+		}
+	}
+
+	return false
+}
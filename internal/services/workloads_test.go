@@ -0,0 +1,89 @@
+package services_test
+
+import (
+	"context"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ConsumingWorkloads", Label("ConsumingWorkloads"), func() {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+
+	newDeployment := func(labels map[string]string, spec corev1.PodSpec) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: labels},
+			Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: spec}},
+		}
+	}
+
+	secretEnv := corev1.EnvVar{Name: "CLIENT_ID", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "token-secret"},
+		Key:                  "clientId",
+	}}}
+
+	DescribeTable("should find the workloads consuming the token",
+		func(deployment *appsv1.Deployment, secretName string, consuming bool) {
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment).Build()
+
+			workloads, err := services.ConsumingWorkloads(ctx, c, "default", "token", secretName)
+			Expect(err).ToNot(HaveOccurred())
+
+			if consuming {
+				Expect(workloads).To(HaveLen(1))
+				Expect(workloads[0].Kind).To(Equal("Deployment"))
+			} else {
+				Expect(workloads).To(BeEmpty())
+			}
+		},
+		Entry("env", newDeployment(nil, corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{secretEnv}}},
+		}), "token-secret", true),
+		Entry("envFrom", newDeployment(nil, corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", EnvFrom: []corev1.EnvFromSource{{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "token-secret"}},
+			}}}},
+		}), "token-secret", true),
+		Entry("volume", newDeployment(nil, corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}},
+			Volumes: []corev1.Volume{{Name: "creds", VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: "token-secret"},
+			}}},
+		}), "token-secret", true),
+		Entry("projected volume", newDeployment(nil, corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}},
+			Volumes: []corev1.Volume{{Name: "creds", VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{{
+					Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "token-secret"}},
+				}}},
+			}}},
+		}), "token-secret", true),
+		Entry("init container", newDeployment(nil, corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init", Env: []corev1.EnvVar{secretEnv}}},
+			Containers:     []corev1.Container{{Name: "app"}},
+		}), "token-secret", true),
+		Entry("opted in by label", newDeployment(map[string]string{v1alpha1.LabelRestartOnRotation: "token"}, corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}},
+		}), "", true),
+		Entry("label of another token", newDeployment(map[string]string{v1alpha1.LabelRestartOnRotation: "other"}, corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}},
+		}), "", false),
+		Entry("another secret", newDeployment(nil, corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{secretEnv}}},
+		}), "other-secret", false),
+		Entry("token without secret", newDeployment(nil, corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{secretEnv}}},
+		}), "", false),
+	)
+})
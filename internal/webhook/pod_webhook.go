@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/secretsinks"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logger "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// PodMutatePath is the path the PodCredentialsInjector is served on
+	PodMutatePath = "/mutate--v1-pod"
+
+	// InjectModeEnv and InjectModeFile are the values of the inject-mode annotation
+	InjectModeEnv  = "env"
+	InjectModeFile = "file"
+
+	// DefaultMountPath is the directory of the injected files unless overridden by the inject-mount-path annotation
+	DefaultMountPath = "/var/run/secrets/cloudflare.zelic.io"
+
+	EnvClientID     = "CF_ACCESS_CLIENT_ID"
+	EnvClientSecret = "CF_ACCESS_CLIENT_SECRET"
+
+	volumeName = "cloudflare-service-token"
+
+	conditionAvailable = "Available"
+	conditionDegraded  = "Degraded"
+)
+
+// +kubebuilder:webhook:path=/mutate--v1-pod,mutating=true,failurePolicy=fail,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod-v1.cloudflare.zelic.io,admissionReviewVersions=v1
+
+// PodCredentialsInjector injects the credentials of the service token named in the inject-service-token label
+// of a Pod, either as env vars or as files. Pods referencing a token which isn't ready are denied.
+type PodCredentialsInjector struct {
+	Client  client.Client
+	Decoder admission.Decoder
+}
+
+func (i *PodCredentialsInjector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := i.Decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	tokenName := pod.Labels[v1alpha1.LabelInjectServiceToken]
+	if tokenName == "" {
+		return admission.Allowed("no service token requested")
+	}
+
+	// the API server would reject the pod with a generic validation error, e.g. for token names longer than 63 characters
+	if errs := validation.IsValidLabelValue(tokenName); len(errs) > 0 {
+		return admission.Denied("invalid value of label " + v1alpha1.LabelInjectServiceToken + ": " + strings.Join(errs, "; "))
+	}
+
+	log := logger.FromContext(ctx).WithName("PodCredentialsInjector").WithValues("namespace", req.Namespace, "token", tokenName)
+
+	token := &v1alpha1.CloudflareServiceToken{}
+	if err := i.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: tokenName}, token); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return admission.Denied("service token " + tokenName + " not found")
+		}
+
+		return admission.Errored(http.StatusInternalServerError, errors.Wrap(err, "unable to get service token"))
+	}
+
+	if reason := notReady(token); reason != "" {
+		return admission.Denied("service token " + tokenName + " is not ready: " + reason)
+	}
+
+	switch mode := pod.Annotations[v1alpha1.AnnotationInjectMode]; mode {
+	case "", InjectModeEnv:
+		injectEnv(pod, token.Status.SecretRef)
+	case InjectModeFile:
+		mountPath := pod.Annotations[v1alpha1.AnnotationInjectMountPath]
+		if mountPath == "" {
+			mountPath = DefaultMountPath
+		}
+		injectFiles(pod, token.Status.SecretRef, mountPath)
+	default:
+		return admission.Denied("unknown inject mode " + mode)
+	}
+
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	log.Info("injecting service token credentials")
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// notReady returns why the credentials of the token can't be injected, or an empty string if they can.
+func notReady(token *v1alpha1.CloudflareServiceToken) string {
+	ref := token.Status.SecretRef
+	switch {
+	case !meta.IsStatusConditionTrue(token.Status.Conditions, conditionAvailable):
+		return "not available"
+	case meta.IsStatusConditionTrue(token.Status.Conditions, conditionDegraded):
+		return "degraded"
+	case ref == nil || ref.Name == "":
+		return "no secret"
+	case ref.Sink != "" && ref.Sink != secretsinks.SinkKubernetes:
+		return "credentials are stored in " + ref.Sink
+	}
+
+	return ""
+}
+
+// injectEnv adds the credentials as env vars to all containers which don't define them yet.
+func injectEnv(pod *corev1.Pod, ref *v1alpha1.SecretRef) {
+	env := []corev1.EnvVar{
+		secretEnvVar(EnvClientID, ref.Name, ref.ClientIDKey),
+		secretEnvVar(EnvClientSecret, ref.Name, ref.ClientSecretKey),
+	}
+
+	forEachContainer(pod, func(container *corev1.Container) {
+		for _, envVar := range env {
+			if !hasEnv(container, envVar.Name) {
+				container.Env = append(container.Env, envVar)
+			}
+		}
+	})
+}
+
+// injectFiles mounts the credentials into all containers as the files CF_ACCESS_CLIENT_ID and CF_ACCESS_CLIENT_SECRET.
+func injectFiles(pod *corev1.Pod, ref *v1alpha1.SecretRef, mountPath string) {
+	if !hasVolume(pod, volumeName) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName: ref.Name,
+				Items: []corev1.KeyToPath{
+					{Key: ref.ClientIDKey, Path: EnvClientID},
+					{Key: ref.ClientSecretKey, Path: EnvClientSecret},
+				},
+			}},
+		})
+	}
+
+	forEachContainer(pod, func(container *corev1.Container) {
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == volumeName || path.Clean(mount.MountPath) == path.Clean(mountPath) {
+				return
+			}
+		}
+
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: mountPath,
+			ReadOnly:  true,
+		})
+	})
+}
+
+func secretEnvVar(name, secretName, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+			Key:                  key,
+		}},
+	}
+}
+
+func forEachContainer(pod *corev1.Pod, fn func(container *corev1.Container)) {
+	for i := range pod.Spec.InitContainers {
+		fn(&pod.Spec.InitContainers[i])
+	}
+
+	for i := range pod.Spec.Containers {
+		fn(&pod.Spec.Containers[i])
+	}
+}
+
+func hasEnv(container *corev1.Container, name string) bool {
+	for _, envVar := range container.Env {
+		if envVar.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasVolume(pod *corev1.Pod, name string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/api/v1alpha1"
+	"github.com/bojanzelic/cloudflare-zero-trust-operator/internal/secretsinks"
+	cfwebhook "github.com/bojanzelic/cloudflare-zero-trust-operator/internal/webhook"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("PodCredentialsInjector", func() {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	newToken := func(name string, available bool, sink string) *v1alpha1.CloudflareServiceToken {
+		status := metav1.ConditionFalse
+		if available {
+			status = metav1.ConditionTrue
+		}
+
+		return &v1alpha1.CloudflareServiceToken{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Status: v1alpha1.CloudflareServiceTokenStatus{
+				SecretRef: &v1alpha1.SecretRef{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name + "-secret"},
+					ClientIDKey:          "clientId",
+					ClientSecretKey:      "clientSecret",
+					Sink:                 sink,
+				},
+				Conditions: []metav1.Condition{{Type: "Available", Status: status, Reason: "Reconciling"}},
+			},
+		}
+	}
+
+	injector := &cfwebhook.PodCredentialsInjector{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newToken("ready", true, secretsinks.SinkKubernetes),
+			newToken("pending", false, secretsinks.SinkKubernetes),
+			newToken("vault", true, secretsinks.SinkVault),
+		).Build(),
+		Decoder: admission.NewDecoder(scheme),
+	}
+
+	admit := func(token string, annotations map[string]string, env ...corev1.EnvVar) admission.Response {
+		labels := map[string]string{}
+		if token != "" {
+			labels[v1alpha1.LabelInjectServiceToken] = token
+		}
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod", Labels: labels, Annotations: annotations},
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "init"}},
+				Containers:     []corev1.Container{{Name: "app", Env: env}},
+			},
+		}
+		raw, err := json.Marshal(pod)
+		Expect(err).ToNot(HaveOccurred())
+
+		return injector.Handle(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: "default",
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}})
+	}
+
+	patchPaths := func(response admission.Response) []string {
+		paths := []string{}
+		for _, patch := range response.Patches {
+			paths = append(paths, patch.Path)
+		}
+
+		return paths
+	}
+
+	It("ignores pods without the annotation", func() {
+		response := admit("", nil)
+		Expect(response.Allowed).To(BeTrue())
+		Expect(response.Patches).To(BeEmpty())
+	})
+
+	It("injects the credentials as env vars", func() {
+		response := admit("ready", nil)
+		Expect(response.Allowed).To(BeTrue())
+		Expect(patchPaths(response)).To(ConsistOf("/spec/initContainers/0/env", "/spec/containers/0/env"))
+
+		env := response.Patches[0].Value.([]any)
+		Expect(env).To(HaveLen(2))
+		Expect(env[1]).To(HaveKeyWithValue("name", cfwebhook.EnvClientSecret))
+		Expect(env[1]).To(HaveKeyWithValue("valueFrom", HaveKeyWithValue("secretKeyRef", map[string]any{
+			"name": "ready-secret",
+			"key":  "clientSecret",
+		})))
+	})
+
+	It("keeps env vars defined by the pod", func() {
+		response := admit(
+			"ready", nil,
+			corev1.EnvVar{Name: cfwebhook.EnvClientID, Value: "custom"},
+		)
+		Expect(response.Allowed).To(BeTrue())
+		Expect(patchPaths(response)).To(ConsistOf("/spec/initContainers/0/env", "/spec/containers/0/env/1"))
+	})
+
+	It("mounts the credentials as files", func() {
+		response := admit("ready", map[string]string{
+			v1alpha1.AnnotationInjectMode:      cfwebhook.InjectModeFile,
+			v1alpha1.AnnotationInjectMountPath: "/etc/cloudflare",
+		})
+		Expect(response.Allowed).To(BeTrue())
+		Expect(patchPaths(response)).To(ConsistOf("/spec/volumes", "/spec/initContainers/0/volumeMounts", "/spec/containers/0/volumeMounts"))
+
+		for _, patch := range response.Patches {
+			if patch.Path == "/spec/containers/0/volumeMounts" {
+				Expect(patch.Value.([]any)[0]).To(HaveKeyWithValue("mountPath", "/etc/cloudflare"))
+			}
+		}
+	})
+
+	It("denies pods referencing a token which isn't ready", func() {
+		for _, name := range []string{"pending", "vault", "missing"} {
+			response := admit(name, nil)
+			Expect(response.Allowed).To(BeFalse(), name)
+		}
+	})
+
+	It("denies invalid label values", func() {
+		response := admit(strings.Repeat("a", 64), nil)
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring(v1alpha1.LabelInjectServiceToken))
+	})
+
+	It("denies unknown inject modes", func() {
+		response := admit("ready", map[string]string{
+			v1alpha1.AnnotationInjectMode: "sidecar",
+		})
+		Expect(response.Allowed).To(BeFalse())
+	})
+})